@@ -3,6 +3,7 @@ package httpclient
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,10 +12,19 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/best-expendables/httpclient/middleware"
+	"github.com/best-expendables/httpclient/net/balancer"
+	"github.com/best-expendables/httpclient/net/discovery"
+	"github.com/best-expendables/httpclient/net/transport"
 )
 
 const (
 	defaultTimeout = 3 * time.Second
+	// BalancedBaseURL base URL of the balanced clients, its scheme and host are replaced by the endpoint
+	// picked for the request. It is seen only in errors of the requests which have not reached the balancer
+	// (e.g. timed out), the reserved ".invalid" domain (RFC 2606) is never resolved without the balancer.
+	BalancedBaseURL = "http://balancer.invalid"
 )
 
 type ResponseParser interface {
@@ -41,9 +51,13 @@ type BaseClient struct {
 	baseUrl        string
 	timeout        time.Duration
 	transport      http.RoundTripper
+	tlsConfig      *tls.Config
+	balancer       *balancer.Balancer
 	httpClient     *http.Client
 	responseParser ResponseParser
 	headerSetterFn HeaderSetterFn
+	// stopWatch stops resolving the endpoints of the discovery client
+	stopWatch func()
 }
 
 type option func(client *BaseClient)
@@ -62,6 +76,30 @@ func WithTransport(rt http.RoundTripper) option {
 	}
 }
 
+// WithTransportConfig new transport with own connection pool, see net/transport
+func WithTransportConfig(config transport.Config) option {
+	return func(client *BaseClient) {
+		client.transport = transport.New(config)
+	}
+}
+
+// WithTLSConfig client TLS configuration, see net/tlsconfig.
+// Applied to the transport when it is not set, is *http.Transport or is built by middleware.WithMiddleware,
+// requests fail for other transports
+func WithTLSConfig(config *tls.Config) option {
+	return func(client *BaseClient) {
+		client.tlsConfig = config
+	}
+}
+
+// WithBalancer spreads requests across the balancer endpoints,
+// scheme and host of the base URL are replaced by the endpoint ones
+func WithBalancer(b *balancer.Balancer) option {
+	return func(client *BaseClient) {
+		client.balancer = b
+	}
+}
+
 // WithResponseParser custom response parsing format
 func WithResponseParser(p ResponseParser) option {
 	return func(client *BaseClient) {
@@ -85,6 +123,12 @@ func NewBaseClient(url string, opts ...option) *BaseClient {
 	for _, opt := range opts {
 		opt(c)
 	}
+	if c.tlsConfig != nil {
+		c.transport = withTLSConfig(c.transport, c.tlsConfig)
+	}
+	if c.balancer != nil {
+		c.transport = middleware.WithMiddleware(c.transport, c.balancer)
+	}
 	c.httpClient = &http.Client{
 		Timeout:   c.timeout,
 		Transport: c.transport,
@@ -92,6 +136,44 @@ func NewBaseClient(url string, opts ...option) *BaseClient {
 	return c
 }
 
+// NewBalancedClient client for multiple endpoints, the endpoint which has served the request
+// is available for middlewares from the response, see balancer.EndpointFromResponse
+func NewBalancedClient(b *balancer.Balancer, opts ...option) *BaseClient {
+	return NewBaseClient(BalancedBaseURL, append(opts, WithBalancer(b))...)
+}
+
+// NewDiscoveryClient balanced (round-robin) client for the resolved endpoints,
+// the endpoints are resolved again with the interval until the context is done or the client is closed
+func NewDiscoveryClient(ctx context.Context, resolver discovery.Resolver, interval time.Duration, opts ...option) (*BaseClient, error) {
+	b, err := discovery.NewBalancer(ctx, resolver, balancer.RoundRobin())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		discovery.Watch(ctx, resolver, b, interval)
+	}()
+
+	c := NewBalancedClient(b, opts...)
+	c.stopWatch = func() {
+		cancel()
+		<-done
+	}
+
+	return c, nil
+}
+
+// Close stops resolving the endpoints of the discovery client, it does nothing for other clients
+func (c *BaseClient) Close() error {
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+	return nil
+}
+
 func (c *BaseClient) DoRequest(ctx context.Context, method, path string, queryParams url.Values, body, result interface{}) error {
 	var (
 		req  *http.Request
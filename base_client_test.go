@@ -7,9 +7,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/best-expendables/httpclient"
+	"github.com/best-expendables/httpclient/net/balancer"
+	"github.com/best-expendables/httpclient/net/discovery"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -108,3 +112,54 @@ func TestBaseClient_WithResponseParser(t *testing.T) {
 	concreteErr, _ := err.(*httpclient.Error)
 	assert.Equal(t, "internal server error", concreteErr.Message)
 }
+
+func TestNewBalancedClient(t *testing.T) {
+	served := make(map[string]int)
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "/v1/test-path", req.URL.Path)
+			served[name]++
+		})
+	}
+	first := httptest.NewServer(handler("first"))
+	defer first.Close()
+	second := httptest.NewServer(handler("second"))
+	defer second.Close()
+
+	b, err := balancer.New(balancer.RoundRobin(), balancer.Targets(first.URL+"/v1", second.URL+"/v1")...)
+	assert.NoError(t, err)
+
+	c := httpclient.NewBalancedClient(b)
+	for i := 0; i < 4; i++ {
+		err = c.DoRequest(context.Background(), http.MethodGet, "/test-path", nil, nil, nil)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, map[string]int{"first": 2, "second": 2}, served)
+}
+
+func TestNewDiscoveryClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var resolved int32
+	resolver := discovery.ResolverFn(func(ctx context.Context) ([]balancer.Target, error) {
+		atomic.AddInt32(&resolved, 1)
+		return balancer.Targets(server.URL), nil
+	})
+	c, err := httpclient.NewDiscoveryClient(ctx, resolver, time.Millisecond)
+	assert.NoError(t, err)
+	assert.NoError(t, c.DoRequest(ctx, http.MethodGet, "/test-path", nil, nil, nil))
+
+	// The endpoints are not resolved after Close
+	assert.NoError(t, c.Close())
+	closed := atomic.LoadInt32(&resolved)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, closed, atomic.LoadInt32(&resolved))
+	assert.NoError(t, c.Close())
+
+	_, err = httpclient.NewDiscoveryClient(ctx, discovery.Static(), time.Minute)
+	assert.Error(t, err)
+}
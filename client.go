@@ -1,19 +1,57 @@
 package httpclient
 
 import (
+	"crypto/tls"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/best-expendables/httpclient/middleware"
+	"github.com/best-expendables/httpclient/net/transport"
 	"github.com/best-expendables/logger"
 )
 
-func NewDefaultHttpClient(defaultEntry logger.Entry, timeout time.Duration) *http.Client {
+type clientOptions struct {
+	tlsConfig       *tls.Config
+	transportConfig *transport.Config
+}
+
+// ClientOption option of NewDefaultHttpClient
+type ClientOption func(options *clientOptions)
+
+// WithClientTLSConfig client TLS configuration, see net/tlsconfig
+func WithClientTLSConfig(config *tls.Config) ClientOption {
+	return func(options *clientOptions) {
+		options.tlsConfig = config
+	}
+}
+
+// WithClientTransportConfig transport settings instead of transport.DefaultConfig
+func WithClientTransportConfig(config transport.Config) ClientOption {
+	return func(options *clientOptions) {
+		options.transportConfig = &config
+	}
+}
+
+func NewDefaultHttpClient(defaultEntry logger.Entry, timeout time.Duration, opts ...ClientOption) *http.Client {
+	options := clientOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	transportConfig := transport.DefaultConfig()
+	if options.transportConfig != nil {
+		transportConfig = *options.transportConfig
+	}
+	if options.tlsConfig != nil {
+		transportConfig.TLSConfig = options.tlsConfig
+	}
+
 	c := &http.Client{
 		Timeout: timeout,
 	}
 	c.Transport = middleware.WithMiddleware(
-		c.Transport,
+		transport.New(transportConfig),
 		middleware.NewResponseLogger(defaultEntry),
 		middleware.NewRequestLogger(defaultEntry),
 		middleware.NewNewrelicApiGateway(middleware.NewURLFormatFunc()),
@@ -24,6 +62,45 @@ func NewDefaultHttpClient(defaultEntry logger.Entry, timeout time.Duration) *htt
 func NewHttpClientWithMiddlewares(timeout time.Duration, middlewares ...middleware.Middleware) *http.Client {
 	return &http.Client{
 		Timeout:   timeout,
-		Transport: middleware.WithMiddleware(http.DefaultTransport, middlewares...),
+		Transport: middleware.WithMiddleware(transport.NewDefault(), middlewares...),
+	}
+}
+
+// withTLSConfig returns a copy of the transport with TLS configuration, new transport is created for nil.
+// Middlewares of WithMiddleware are built again over the copy of the underlying transport.
+// Other round trippers fail the requests, the TLS configuration can not be applied to them.
+func withTLSConfig(rt http.RoundTripper, config *tls.Config) http.RoundTripper {
+	if rt == nil {
+		transportConfig := transport.DefaultConfig()
+		transportConfig.TLSConfig = config
+		return transport.New(transportConfig)
+	}
+
+	if httpTransport, ok := rt.(*http.Transport); ok {
+		httpTransport = httpTransport.Clone()
+		httpTransport.TLSClientConfig = config
+
+		return httpTransport
+	}
+
+	rewrapped, ok := middleware.Rewrap(rt, func(transport http.RoundTripper) http.RoundTripper {
+		return withTLSConfig(transport, config)
+	})
+	if ok {
+		return rewrapped
+	}
+
+	return failingTransport{err: fmt.Errorf("httpclient: TLS configuration can not be applied to %T, configure the underlying *http.Transport instead", rt)}
+}
+
+// failingTransport fails all the requests with the error
+type failingTransport struct {
+	err error
+}
+
+func (t failingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Body != nil {
+		request.Body.Close()
 	}
+	return nil, t.err
 }
@@ -2,9 +2,15 @@ package httpclient
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/best-expendables/httpclient/middleware"
+	"github.com/best-expendables/httpclient/net/transport"
 	"github.com/best-expendables/logger"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,3 +22,69 @@ func TestNewClient(t *testing.T) {
 	asserts.NotNil(c)
 	asserts.Equal(2*time.Second, c.Timeout)
 }
+
+func TestWithTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	config := &tls.Config{RootCAs: pool}
+
+	asserts := assert.New(t)
+
+	c := NewDefaultHttpClient(logger.EntryFromContext(context.Background()), 2*time.Second, WithClientTLSConfig(config))
+	response, err := c.Get(server.URL)
+	if asserts.NoError(err) {
+		response.Body.Close()
+	}
+
+	err = NewBaseClient(server.URL, WithTLSConfig(config)).DoRequest(context.Background(), http.MethodGet, "/", nil, nil, nil)
+	asserts.NoError(err)
+
+	// Untrusted server certificate
+	err = NewBaseClient(server.URL).DoRequest(context.Background(), http.MethodGet, "/", nil, nil, nil)
+	asserts.Error(err)
+
+	// TLS configuration is applied to the transport under the middlewares
+	var calls int
+	wrapped := middleware.WithMiddleware(nil, middlewareFn(func(next http.RoundTripper) http.RoundTripper {
+		return middleware.RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+			calls++
+			return next.RoundTrip(request)
+		})
+	}))
+	err = NewBaseClient(server.URL, WithTransport(wrapped), WithTLSConfig(config)).DoRequest(context.Background(), http.MethodGet, "/", nil, nil, nil)
+	asserts.NoError(err)
+	asserts.Equal(1, calls, "middlewares are kept")
+
+	// Custom round tripper can not be configured, requests fail instead of skipping the TLS configuration
+	custom := middleware.RoundTripperFn(http.DefaultTransport.RoundTrip)
+	err = NewBaseClient(server.URL, WithTransport(custom), WithTLSConfig(config)).DoRequest(context.Background(), http.MethodGet, "/", nil, nil, nil)
+	asserts.Error(err)
+
+	// http.DefaultTransport is not modified
+	if defaultConfig := http.DefaultTransport.(*http.Transport).TLSClientConfig; defaultConfig != nil {
+		asserts.Nil(defaultConfig.RootCAs)
+	}
+}
+
+// middlewareFn function as a middleware
+type middlewareFn func(next http.RoundTripper) http.RoundTripper
+
+func (fn middlewareFn) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return fn(next)
+}
+
+func TestWithTransportConfig(t *testing.T) {
+	config := transport.DefaultConfig()
+	config.MaxIdleConnsPerHost = 64
+
+	c := NewBaseClient("http://localhost", WithTransportConfig(config), WithTLSConfig(&tls.Config{ServerName: "localhost"}))
+
+	asserts := assert.New(t)
+	if httpTransport, ok := c.transport.(*http.Transport); asserts.True(ok) {
+		asserts.Equal(64, httpTransport.MaxIdleConnsPerHost)
+		asserts.Equal("localhost", httpTransport.TLSClientConfig.ServerName)
+	}
+}
@@ -1,16 +1,18 @@
 module github.com/best-expendables/httpclient
 
-go 1.12
+go 1.15
 
 require (
 	github.com/best-expendables/logger v0.0.0-20200511084842-8247cf6c59bd
 	github.com/best-expendables/trace v0.0.0-20200511055751-fb29d033fd2d
 	github.com/best-expendables/user-service-client v0.0.0-20200511060456-3fcf8ea240f5 // indirect
 	github.com/newrelic/go-agent v2.14.1+incompatible
+	github.com/newrelic/go-agent/v3 v3.6.0
 	github.com/onsi/ginkgo v1.12.0 // indirect
 	github.com/onsi/gomega v1.9.0 // indirect
 	github.com/opentracing/opentracing-go v1.1.0
 	github.com/sirupsen/logrus v1.4.2 // indirect
 	github.com/stretchr/testify v1.4.0
 	gopkg.in/redis.v5 v5.2.9 // indirect
+	gopkg.in/yaml.v2 v2.2.4
 )
@@ -0,0 +1,119 @@
+package httpclienttest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// cassetteVersion format version of the cassette file
+const cassetteVersion = 1
+
+type (
+	// Cassette recorded interactions, stored as JSON or YAML file (by extension)
+	Cassette struct {
+		Version      int            `json:"version" yaml:"version"`
+		Interactions []*Interaction `json:"interactions" yaml:"interactions"`
+	}
+
+	// Interaction recorded request with the response
+	Interaction struct {
+		Request  RecordedRequest  `json:"request" yaml:"request"`
+		Response RecordedResponse `json:"response" yaml:"response"`
+	}
+
+	// RecordedRequest request as it is stored in the cassette, secrets are redacted
+	RecordedRequest struct {
+		Method  string      `json:"method" yaml:"method"`
+		URL     string      `json:"url" yaml:"url"`
+		Headers http.Header `json:"headers,omitempty" yaml:"headers,omitempty"`
+		Body    Body        `json:"body,omitempty" yaml:"body,omitempty"`
+	}
+
+	// RecordedResponse response as it is stored in the cassette, secrets are redacted
+	RecordedResponse struct {
+		StatusCode int         `json:"status_code" yaml:"status_code"`
+		Headers    http.Header `json:"headers,omitempty" yaml:"headers,omitempty"`
+		Body       Body        `json:"body,omitempty" yaml:"body,omitempty"`
+	}
+
+	// Body text or base64 encoded binary content
+	Body struct {
+		Text     string `json:"text,omitempty" yaml:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+	}
+)
+
+// LoadCassette reads ".json", ".yaml" or ".yml" cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cassette := new(Cassette)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(content, cassette)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, cassette)
+	default:
+		err = fmt.Errorf("httpclienttest: unsupported cassette format %q", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cassette, nil
+}
+
+// Save writes the cassette, the format is chosen by the extension
+func (c *Cassette) Save(path string) error {
+	c.Version = cassetteVersion
+
+	var (
+		content []byte
+		err     error
+	)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		content, err = json.MarshalIndent(c, "", "  ")
+	case ".yaml", ".yml":
+		content, err = yaml.Marshal(c)
+	default:
+		err = fmt.Errorf("httpclienttest: unsupported cassette format %q", ext)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// newBody text body, binary content is base64 encoded
+func newBody(content []byte) Body {
+	if utf8.Valid(content) {
+		return Body{Text: string(content)}
+	}
+	return Body{Text: base64.StdEncoding.EncodeToString(content), Encoding: "base64"}
+}
+
+// Bytes decoded content
+func (b Body) Bytes() ([]byte, error) {
+	if b.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(b.Text)
+	}
+	return []byte(b.Text), nil
+}
@@ -0,0 +1,109 @@
+package httpclienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// Matcher compares the request with the recorded one, both are redacted
+type Matcher func(request, recorded *RecordedRequest) bool
+
+// DefaultMatcher matches method, URL and query
+var DefaultMatcher = MatchAll(MatchMethod(), MatchURL(), MatchQuery())
+
+// MatchAll matches if all the matchers match
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(request, recorded *RecordedRequest) bool {
+		for _, matcher := range matchers {
+			if !matcher(request, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchMethod compares HTTP method
+func MatchMethod() Matcher {
+	return func(request, recorded *RecordedRequest) bool {
+		return request.Method == recorded.Method
+	}
+}
+
+// MatchURL compares scheme, host and path, the query is ignored
+func MatchURL() Matcher {
+	return func(request, recorded *RecordedRequest) bool {
+		requestURL, err := url.Parse(request.URL)
+		if err != nil {
+			return false
+		}
+		recordedURL, err := url.Parse(recorded.URL)
+		if err != nil {
+			return false
+		}
+
+		return requestURL.Scheme == recordedURL.Scheme &&
+			requestURL.Host == recordedURL.Host &&
+			requestURL.Path == recordedURL.Path
+	}
+}
+
+// MatchQuery compares query parameters regardless of the order
+func MatchQuery() Matcher {
+	return func(request, recorded *RecordedRequest) bool {
+		return reflect.DeepEqual(query(request.URL), query(recorded.URL))
+	}
+}
+
+// MatchHeaders compares values of the headers
+func MatchHeaders(names ...string) Matcher {
+	return func(request, recorded *RecordedRequest) bool {
+		for _, name := range names {
+			name = http.CanonicalHeaderKey(name)
+			if !reflect.DeepEqual(request.Headers[name], recorded.Headers[name]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchBody compares bodies, JSON bodies are compared regardless of formatting and key order
+func MatchBody() Matcher {
+	return func(request, recorded *RecordedRequest) bool {
+		requestBody, err := request.Body.Bytes()
+		if err != nil {
+			return false
+		}
+		recordedBody, err := recorded.Body.Bytes()
+		if err != nil {
+			return false
+		}
+
+		if bytes.Equal(requestBody, recordedBody) {
+			return true
+		}
+
+		var requestJSON, recordedJSON interface{}
+		if json.Unmarshal(requestBody, &requestJSON) != nil || json.Unmarshal(recordedBody, &recordedJSON) != nil {
+			return false
+		}
+		return reflect.DeepEqual(requestJSON, recordedJSON)
+	}
+}
+
+func query(rawURL string) url.Values {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	values, _ := url.ParseQuery(u.RawQuery)
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
@@ -0,0 +1,327 @@
+package httpclienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// AnyTimes expectation may be called any number of times, including zero
+const AnyTimes = -1
+
+// ErrUnexpectedRequest returned by MockTransport when no expectation matches the request
+var ErrUnexpectedRequest = errors.New("httpclienttest: unexpected request")
+
+// TestingT subset of *testing.T used for verification
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// ResponderFn builds the response of the expectation
+type ResponderFn func(request *http.Request) (*http.Response, error)
+
+// MockTransport http.RoundTripper which serves requests from the expectations
+type MockTransport struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	ordered      bool
+	next         int
+	unexpected   []string
+}
+
+// Expectation expected request with the canned response
+type Expectation struct {
+	method   string
+	pattern  string
+	query    map[string][]string
+	headers  http.Header
+	body     *string
+	jsonBody interface{}
+
+	responder ResponderFn
+	times     int
+	// mu lock of the mock transport, guards calls
+	mu    *sync.Mutex
+	calls int
+}
+
+// NewMockTransport mock transport without expectations, every request fails
+func NewMockTransport() *MockTransport {
+	return &MockTransport{}
+}
+
+// InOrder expectations must be met in the order they are declared
+func (m *MockTransport) InOrder() *MockTransport {
+	m.ordered = true
+	return m
+}
+
+// Expect adds an expectation, pattern is matched against URL path with path.Match ("/users/*"),
+// empty method matches any method. Expectation responds 200 with empty body once by default
+func (m *MockTransport) Expect(method, pattern string) *Expectation {
+	expectation := &Expectation{
+		method:    method,
+		pattern:   pattern,
+		query:     make(map[string][]string),
+		headers:   http.Header{},
+		responder: respond(http.StatusOK, nil, nil),
+		times:     1,
+		mu:        &m.mu,
+	}
+
+	m.mu.Lock()
+	m.expectations = append(m.expectations, expectation)
+	m.mu.Unlock()
+
+	return expectation
+}
+
+// RoundTrip responds with the first matching expectation
+func (m *MockTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request, body, err := readBody(request)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	expectation, mismatches := m.find(request, body)
+	if expectation == nil {
+		message := fmt.Sprintf("%s %s", request.Method, request.URL)
+		if len(mismatches) > 0 {
+			message += "\n" + strings.Join(mismatches, "\n")
+		}
+		m.unexpected = append(m.unexpected, message)
+		m.mu.Unlock()
+
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedRequest, message)
+	}
+	expectation.calls++
+	responder := expectation.responder
+	m.mu.Unlock()
+
+	return responder(request)
+}
+
+// AssertExpectations reports unexpected requests and expectations called wrong number of times
+func (m *MockTransport) AssertExpectations(t TestingT) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok := true
+	for _, message := range m.unexpected {
+		t.Errorf("httpclienttest: unexpected request %s", message)
+		ok = false
+	}
+	for _, expectation := range m.expectations {
+		if expectation.times != AnyTimes && expectation.calls != expectation.times {
+			t.Errorf("httpclienttest: %s expected %d call(s), got %d", expectation, expectation.times, expectation.calls)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// find matching expectation, mismatch reasons of the candidates otherwise. Caller holds the lock
+func (m *MockTransport) find(request *http.Request, body []byte) (*Expectation, []string) {
+	var mismatches []string
+	for i := m.next; i < len(m.expectations); i++ {
+		expectation := m.expectations[i]
+		if expectation.exhausted() {
+			continue
+		}
+
+		reasons := expectation.mismatches(request, body)
+		if len(reasons) == 0 {
+			if m.ordered {
+				m.next = i
+			}
+			return expectation, nil
+		}
+		mismatches = append(mismatches, fmt.Sprintf("  %s: %s", expectation, strings.Join(reasons, "; ")))
+
+		if m.ordered && !expectation.satisfied() {
+			break
+		}
+	}
+
+	return nil, mismatches
+}
+
+// WithQuery expects query parameter with the values, in any order of the parameters
+func (e *Expectation) WithQuery(key string, values ...string) *Expectation {
+	e.query[key] = values
+	return e
+}
+
+// WithHeader expects request header value
+func (e *Expectation) WithHeader(name, value string) *Expectation {
+	e.headers.Set(name, value)
+	return e
+}
+
+// WithBody expects exact request body
+func (e *Expectation) WithBody(body string) *Expectation {
+	e.body = &body
+	return e
+}
+
+// WithJSONBody expects JSON body equal to the value marshaled to JSON, regardless of formatting and key order
+func (e *Expectation) WithJSONBody(value interface{}) *Expectation {
+	e.jsonBody = normalizeJSON(value)
+	return e
+}
+
+// Respond canned response with the body
+func (e *Expectation) Respond(statusCode int, body string) *Expectation {
+	e.responder = respond(statusCode, nil, []byte(body))
+	return e
+}
+
+// RespondJSON canned JSON response, value is marshaled to JSON
+func (e *Expectation) RespondJSON(statusCode int, value interface{}) *Expectation {
+	body, err := json.Marshal(value)
+	if err != nil {
+		e.responder = fail(err)
+		return e
+	}
+
+	e.responder = respond(statusCode, http.Header{"Content-Type": {"application/json"}}, body)
+	return e
+}
+
+// RespondWith builds the response by the function, e.g. to echo the request
+func (e *Expectation) RespondWith(responder ResponderFn) *Expectation {
+	e.responder = responder
+	return e
+}
+
+// RespondError transport error, e.g. to test retries
+func (e *Expectation) RespondError(err error) *Expectation {
+	e.responder = fail(err)
+	return e
+}
+
+// Times expected number of calls, AnyTimes for unlimited
+func (e *Expectation) Times(times int) *Expectation {
+	e.times = times
+	return e
+}
+
+// Calls number of the matched requests
+func (e *Expectation) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.calls
+}
+
+func (e *Expectation) String() string {
+	method := e.method
+	if method == "" {
+		method = "*"
+	}
+	return fmt.Sprintf("%s %s", method, e.pattern)
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.times != AnyTimes && e.calls >= e.times
+}
+
+func (e *Expectation) satisfied() bool {
+	return e.times == AnyTimes || e.calls >= e.times
+}
+
+// mismatches human readable differences between the expectation and the request
+func (e *Expectation) mismatches(request *http.Request, body []byte) []string {
+	var reasons []string
+
+	if e.method != "" && e.method != request.Method {
+		reasons = append(reasons, fmt.Sprintf("method: expected %s, got %s", e.method, request.Method))
+	}
+	if matched, _ := path.Match(e.pattern, request.URL.Path); !matched {
+		reasons = append(reasons, fmt.Sprintf("path: expected %s, got %s", e.pattern, request.URL.Path))
+	}
+
+	query := request.URL.Query()
+	for _, key := range sortedKeys(e.query) {
+		if !reflect.DeepEqual(e.query[key], query[key]) {
+			reasons = append(reasons, fmt.Sprintf("query %s: expected %q, got %q", key, e.query[key], query[key]))
+		}
+	}
+	for _, name := range sortedKeys(e.headers) {
+		if value, actual := e.headers.Get(name), request.Header.Get(name); actual != value {
+			reasons = append(reasons, fmt.Sprintf("header %s: expected %q, got %q", name, value, actual))
+		}
+	}
+
+	if e.body != nil && *e.body != string(body) {
+		reasons = append(reasons, fmt.Sprintf("body: expected %q, got %q", *e.body, body))
+	}
+	if e.jsonBody != nil {
+		var actual interface{}
+		if err := json.Unmarshal(body, &actual); err != nil {
+			reasons = append(reasons, fmt.Sprintf("body: expected JSON, got %q", body))
+		} else if !reflect.DeepEqual(e.jsonBody, actual) {
+			reasons = append(reasons, fmt.Sprintf("body: expected %s, got %s", compactJSON(e.jsonBody), compactJSON(actual)))
+		}
+	}
+
+	return reasons
+}
+
+func respond(statusCode int, header http.Header, body []byte) ResponderFn {
+	return func(request *http.Request) (*http.Response, error) {
+		responseHeader := http.Header{}
+		for name, values := range header {
+			responseHeader[name] = append([]string(nil), values...)
+		}
+		return newResponse(request, statusCode, responseHeader, body), nil
+	}
+}
+
+func fail(err error) ResponderFn {
+	return func(*http.Request) (*http.Response, error) {
+		return nil, err
+	}
+}
+
+// normalizeJSON value as it is decoded from JSON, so it can be compared with the decoded body
+func normalizeJSON(value interface{}) interface{} {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+
+	var normalized interface{}
+	if err := json.Unmarshal(content, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+func compactJSON(value interface{}) string {
+	buf := bytes.Buffer{}
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,128 @@
+package httpclienttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/best-expendables/httpclient"
+	"github.com/best-expendables/httpclient/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMockTransport_BaseClient(t *testing.T) {
+	mock := NewMockTransport()
+	mock.Expect(http.MethodPost, "/users").
+		WithQuery("notify", "true").
+		WithHeader("Content-Type", "application/json").
+		WithJSONBody(map[string]interface{}{"name": "John", "age": 30}).
+		RespondJSON(http.StatusCreated, map[string]interface{}{"data": map[string]interface{}{"id": 7}})
+
+	client := httpclient.NewBaseClient("http://users.local", httpclient.WithTransport(mock))
+
+	var result struct {
+		ID int `json:"id"`
+	}
+	body := map[string]interface{}{"age": 30, "name": "John"}
+	err := client.DoRequest(context.Background(), http.MethodPost, "/users", map[string][]string{"notify": {"true"}}, body, &result)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 7, result.ID)
+	assert.True(t, mock.AssertExpectations(t))
+}
+
+func TestMockTransport_Mismatch(t *testing.T) {
+	mock := NewMockTransport()
+	expectation := mock.Expect(http.MethodGet, "/users/*").WithQuery("page", "2").Respond(http.StatusOK, "ok")
+	client := &http.Client{Transport: mock}
+
+	_, err := client.Get("http://users.local/users/1?page=1")
+	assert.True(t, errors.Is(err, ErrUnexpectedRequest))
+	assert.Contains(t, err.Error(), `GET /users/*: query page: expected ["2"], got ["1"]`)
+
+	_, err = client.Post("http://users.local/orders", "text/plain", strings.NewReader("x"))
+	assert.Contains(t, err.Error(), "method: expected GET, got POST; path: expected /users/*, got /orders")
+
+	recorder := &recordingT{}
+	assert.False(t, mock.AssertExpectations(recorder))
+	assert.Len(t, recorder.errors, 3)
+	assert.Contains(t, recorder.errors[2], "GET /users/* expected 1 call(s), got 0")
+	assert.Equal(t, 0, expectation.Calls())
+}
+
+func TestMockTransport_JSONBodyDiff(t *testing.T) {
+	mock := NewMockTransport()
+	mock.Expect(http.MethodPost, "/users").WithJSONBody(map[string]string{"name": "John"})
+
+	_, err := (&http.Client{Transport: mock}).Post("http://users.local/users", "application/json", strings.NewReader(`{"name":"Jane"}`))
+	assert.Contains(t, err.Error(), `body: expected {"name":"John"}, got {"name":"Jane"}`)
+}
+
+func TestMockTransport_Times(t *testing.T) {
+	mock := NewMockTransport()
+	mock.Expect(http.MethodGet, "/status").Respond(http.StatusServiceUnavailable, "").Times(2)
+	mock.Expect(http.MethodGet, "/status").Respond(http.StatusOK, "up")
+	mock.Expect("", "/metrics").Times(AnyTimes)
+	client := &http.Client{Transport: mock}
+
+	var statuses []int
+	for i := 0; i < 3; i++ {
+		response, err := client.Get("http://service.local/status")
+		assert.NoError(t, err)
+		statuses = append(statuses, response.StatusCode)
+	}
+
+	assert.Equal(t, []int{503, 503, 200}, statuses)
+	assert.True(t, mock.AssertExpectations(t))
+
+	_, err := client.Get("http://service.local/status")
+	assert.Error(t, err, "all expectations are exhausted")
+}
+
+func TestMockTransport_InOrder(t *testing.T) {
+	mock := NewMockTransport().InOrder()
+	mock.Expect(http.MethodPost, "/login").Respond(http.StatusOK, "token")
+	mock.Expect(http.MethodGet, "/profile").Respond(http.StatusOK, "profile")
+	client := &http.Client{Transport: mock}
+
+	_, err := client.Get("http://service.local/profile")
+	assert.True(t, errors.Is(err, ErrUnexpectedRequest), "login is expected first")
+
+	_, err = client.Post("http://service.local/login", "", nil)
+	assert.NoError(t, err)
+	_, err = client.Get("http://service.local/profile")
+	assert.NoError(t, err)
+}
+
+func TestMockTransport_RespondWith(t *testing.T) {
+	mock := NewMockTransport()
+	mock.Expect(http.MethodPut, "/echo").RespondWith(func(request *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(request.Body)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(string(body))), Request: request}, nil
+	})
+	mock.Expect(http.MethodGet, "/broken").RespondError(errors.New("connection reset"))
+
+	client := &http.Client{Transport: middleware.WithMiddleware(mock)}
+
+	request, _ := http.NewRequest(http.MethodPut, "http://service.local/echo", strings.NewReader("hello"))
+	response, err := client.Do(request)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, "hello", string(body))
+
+	_, err = client.Get("http://service.local/broken")
+	assert.Contains(t, err.Error(), "connection reset")
+	assert.True(t, mock.AssertExpectations(t))
+}
@@ -0,0 +1,270 @@
+package httpclienttest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/best-expendables/httpclient/middleware"
+	"github.com/best-expendables/httpclient/net/transport"
+)
+
+// Mode of the recorder
+type Mode int
+
+const (
+	// ModeReplay replays the cassette, requests without recorded interaction fail
+	ModeReplay Mode = iota
+	// ModeRecord sends all the requests and records a new cassette
+	ModeRecord
+	// ModeRecordNewEpisodes replays recorded interactions, records the rest
+	ModeRecordNewEpisodes
+	// ModePassthrough sends all the requests, the cassette is not used
+	ModePassthrough
+)
+
+// ErrInteractionNotFound returned in replay mode when no recorded interaction matches the request
+var ErrInteractionNotFound = errors.New("httpclienttest: interaction not found")
+
+// Recorder http.RoundTripper which records interactions to the cassette file and replays them
+type Recorder struct {
+	path      string
+	mode      Mode
+	transport http.RoundTripper
+	matcher   Matcher
+	redactor  *middleware.Redactor
+	hook      func(interaction *Interaction)
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     map[*Interaction]bool
+	changed  bool
+}
+
+// NewRecorder loads the cassette, the file is required in replay mode
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	cassette := new(Cassette)
+	if mode != ModeRecord && mode != ModePassthrough {
+		loaded, err := LoadCassette(path)
+		switch {
+		case err == nil:
+			cassette = loaded
+		case os.IsNotExist(err) && mode == ModeRecordNewEpisodes:
+		default:
+			return nil, err
+		}
+	}
+
+	return &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: transport.NewDefault(),
+		matcher:   DefaultMatcher,
+		redactor:  middleware.DefaultRedactor(),
+		cassette:  cassette,
+		used:      make(map[*Interaction]bool),
+	}, nil
+}
+
+// WithTransport transport for the real requests
+func (r *Recorder) WithTransport(transport http.RoundTripper) *Recorder {
+	r.transport = transport
+	return r
+}
+
+// WithMatcher custom matching of the requests, DefaultMatcher by default
+func (r *Recorder) WithMatcher(matcher Matcher) *Recorder {
+	r.matcher = matcher
+	return r
+}
+
+// WithRedactor redacts secrets before saving, nil disables redaction
+func (r *Recorder) WithRedactor(redactor *middleware.Redactor) *Recorder {
+	r.redactor = redactor
+	return r
+}
+
+// WithHook modifies the interaction before it is recorded
+func (r *Recorder) WithHook(hook func(interaction *Interaction)) *Recorder {
+	r.hook = hook
+	return r
+}
+
+// Cassette recorded interactions
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cassette
+}
+
+// RoundTrip replays the matching interaction or sends the request depending on the mode
+func (r *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	if r.mode == ModePassthrough {
+		return r.transport.RoundTrip(request)
+	}
+
+	request, body, err := readBody(request)
+	if err != nil {
+		return nil, err
+	}
+	recorded := r.request(request, body)
+
+	if r.mode != ModeRecord {
+		if interaction := r.find(&recorded); interaction != nil {
+			return replay(request, interaction)
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, request.Method, r.redactor.URL(request.URL))
+		}
+	}
+
+	response, err := r.transport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	interaction := &Interaction{Request: recorded, Response: r.response(response, responseBody)}
+	if r.hook != nil {
+		r.hook(interaction)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.used[interaction] = true
+	r.changed = true
+	r.mu.Unlock()
+
+	return response, nil
+}
+
+// Stop saves the cassette if new interactions were recorded
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.changed {
+		return nil
+	}
+	if err := r.cassette.Save(r.path); err != nil {
+		return err
+	}
+	r.changed = false
+
+	return nil
+}
+
+// find first unused matching interaction, the last matching one if all are used
+func (r *Recorder) find(request *RecordedRequest) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last *Interaction
+	for _, interaction := range r.cassette.Interactions {
+		if !r.matcher(request, &interaction.Request) {
+			continue
+		}
+		if !r.used[interaction] {
+			r.used[interaction] = true
+			return interaction
+		}
+		last = interaction
+	}
+
+	return last
+}
+
+// request redacted the same way for recording and matching, so redacted cassettes still match
+func (r *Recorder) request(request *http.Request, body []byte) RecordedRequest {
+	return RecordedRequest{
+		Method:  request.Method,
+		URL:     r.redactor.URL(request.URL),
+		Headers: r.redactor.Header(request.Header),
+		Body:    r.body(request.Header, body),
+	}
+}
+
+func (r *Recorder) response(response *http.Response, body []byte) RecordedResponse {
+	return RecordedResponse{
+		StatusCode: response.StatusCode,
+		Headers:    r.redactor.Header(response.Header),
+		Body:       r.body(response.Header, body),
+	}
+}
+
+func (r *Recorder) body(header http.Header, body []byte) Body {
+	recorded := newBody(body)
+	if recorded.Encoding != "" || len(body) == 0 {
+		return recorded
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		if values, err := url.ParseQuery(recorded.Text); err == nil {
+			recorded.Text = r.redactor.Form(values).Encode()
+			return recorded
+		}
+	}
+	recorded.Text = r.redactor.Body(recorded.Text)
+
+	return recorded
+}
+
+func replay(request *http.Request, interaction *Interaction) (*http.Response, error) {
+	body, err := interaction.Response.Body.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	for name, values := range interaction.Response.Headers {
+		header[name] = append([]string(nil), values...)
+	}
+
+	return newResponse(request, interaction.Response.StatusCode, header, body), nil
+}
+
+func newResponse(request *http.Request, statusCode int, header http.Header, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}
+}
+
+// readBody reads the request body, the returned copy of the request has the body restored for the transport
+func readBody(request *http.Request) (*http.Request, []byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return request, nil, nil
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clone := new(http.Request)
+	*clone = *request
+	clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return clone, body, nil
+}
@@ -0,0 +1,196 @@
+package httpclienttest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/best-expendables/httpclient"
+	"github.com/best-expendables/httpclient/middleware"
+	"github.com/stretchr/testify/suite"
+)
+
+type RecorderSuite struct {
+	suite.Suite
+	server *httptest.Server
+	hits   int32
+}
+
+func (s *RecorderSuite) SetupSuite() {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.hits, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		switch r.URL.Path {
+		case "/echo":
+			w.Write(body)
+		default:
+			w.Write([]byte(`{"data":{"id":1,"path":"` + r.URL.Path + `","access_token":"secret-token"}}`))
+		}
+	}))
+}
+
+func (s *RecorderSuite) TearDownSuite() {
+	s.server.Close()
+}
+
+func (s *RecorderSuite) SetupTest() {
+	atomic.StoreInt32(&s.hits, 0)
+}
+
+func (s *RecorderSuite) get(recorder *Recorder, path string) (string, error) {
+	request, _ := http.NewRequest(http.MethodGet, s.server.URL+path, nil)
+	request.Header.Set("Authorization", "Bearer header-secret")
+
+	response, err := (&http.Client{Transport: recorder}).Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	return string(body), err
+}
+
+func (s *RecorderSuite) TestRecordAndReplay() {
+	for _, name := range []string{"cassette.json", "cassette.yaml"} {
+		path := filepath.Join(s.T().TempDir(), "fixtures", name)
+		atomic.StoreInt32(&s.hits, 0)
+
+		recorder, err := NewRecorder(path, ModeRecord)
+		s.NoError(err)
+
+		body, err := s.get(recorder, "/users?page=2&token=query-secret")
+		s.NoError(err)
+		s.Contains(body, "secret-token", "caller gets the original body")
+		s.NoError(recorder.Stop())
+
+		content, err := ioutil.ReadFile(path)
+		s.NoError(err)
+		s.NotContains(string(content), "secret", name)
+		s.Contains(string(content), middleware.DefaultRedactionMask, name)
+
+		replayer, err := NewRecorder(path, ModeReplay)
+		s.NoError(err)
+
+		body, err = s.get(replayer, "/users?token=query-secret&page=2")
+		s.NoError(err)
+		s.Contains(body, `"path":"/users"`, name)
+		s.EqualValues(1, atomic.LoadInt32(&s.hits), "replay does not hit the server")
+
+		_, err = s.get(replayer, "/orders")
+		s.True(errors.Is(err, ErrInteractionNotFound), name)
+	}
+}
+
+func (s *RecorderSuite) TestReplayMissingCassette() {
+	_, err := NewRecorder(filepath.Join(s.T().TempDir(), "missing.json"), ModeReplay)
+	s.Error(err)
+}
+
+func (s *RecorderSuite) TestRecordNewEpisodes() {
+	path := filepath.Join(s.T().TempDir(), "cassette.json")
+
+	recorder, err := NewRecorder(path, ModeRecordNewEpisodes)
+	s.NoError(err)
+	_, err = s.get(recorder, "/users")
+	s.NoError(err)
+	s.NoError(recorder.Stop())
+
+	recorder, err = NewRecorder(path, ModeRecordNewEpisodes)
+	s.NoError(err)
+	_, err = s.get(recorder, "/users")
+	s.NoError(err)
+	_, err = s.get(recorder, "/orders")
+	s.NoError(err)
+	s.NoError(recorder.Stop())
+
+	s.EqualValues(2, atomic.LoadInt32(&s.hits))
+	s.Len(recorder.Cassette().Interactions, 2)
+}
+
+func (s *RecorderSuite) TestSequentialInteractions() {
+	path := filepath.Join(s.T().TempDir(), "cassette.json")
+	cassette := new(Cassette)
+	for _, id := range []string{"1", "2"} {
+		cassette.Interactions = append(cassette.Interactions, &Interaction{
+			Request:  RecordedRequest{Method: http.MethodGet, URL: s.server.URL + "/poll"},
+			Response: RecordedResponse{StatusCode: http.StatusOK, Body: Body{Text: id}},
+		})
+	}
+	s.NoError(cassette.Save(path))
+
+	recorder, err := NewRecorder(path, ModeReplay)
+	s.NoError(err)
+
+	for _, expected := range []string{"1", "2", "2"} {
+		body, err := s.get(recorder, "/poll")
+		s.NoError(err)
+		s.Equal(expected, body)
+	}
+}
+
+func (s *RecorderSuite) TestMatchBody() {
+	path := filepath.Join(s.T().TempDir(), "cassette.json")
+	matcher := MatchAll(DefaultMatcher, MatchHeaders("Content-Type"), MatchBody())
+
+	recorder, _ := NewRecorder(path, ModeRecordNewEpisodes)
+	recorder.WithMatcher(matcher)
+	client := &http.Client{Transport: recorder}
+
+	post := func(body string) string {
+		response, err := client.Post(s.server.URL+"/echo", "application/json", strings.NewReader(body))
+		s.NoError(err)
+		defer response.Body.Close()
+		content, _ := ioutil.ReadAll(response.Body)
+		return string(content)
+	}
+
+	s.Equal(`{"a":1,"b":2}`, post(`{"a":1,"b":2}`))
+	s.Equal(`{"a":1,"b":2}`, post(`{ "b": 2, "a": 1 }`), "JSON bodies are normalized")
+	s.Equal(`{"a":3}`, post(`{"a":3}`))
+	s.EqualValues(2, atomic.LoadInt32(&s.hits))
+}
+
+func (s *RecorderSuite) TestPassthrough() {
+	recorder, err := NewRecorder(filepath.Join(s.T().TempDir(), "cassette.json"), ModePassthrough)
+	s.NoError(err)
+
+	_, err = s.get(recorder, "/users")
+	s.NoError(err)
+	s.Empty(recorder.Cassette().Interactions)
+	s.NoError(recorder.Stop())
+}
+
+func (s *RecorderSuite) TestBaseClient() {
+	path := filepath.Join(s.T().TempDir(), "cassette.yml")
+	type user struct {
+		ID   int    `json:"id"`
+		Path string `json:"path"`
+	}
+
+	recorder, _ := NewRecorder(path, ModeRecord)
+	client := httpclient.NewBaseClient(s.server.URL, httpclient.WithTransport(recorder))
+	s.NoError(client.DoRequest(context.Background(), http.MethodGet, "/users/1", nil, nil, new(user)))
+	s.NoError(recorder.Stop())
+
+	replayer, err := NewRecorder(path, ModeReplay)
+	s.NoError(err)
+	client = httpclient.NewBaseClient(s.server.URL, httpclient.WithTransport(replayer))
+
+	result := new(user)
+	s.NoError(client.DoRequest(context.Background(), http.MethodGet, "/users/1", nil, nil, result))
+	s.Equal(user{ID: 1, Path: "/users/1"}, *result)
+	s.EqualValues(1, atomic.LoadInt32(&s.hits))
+}
+
+func TestRecorder(t *testing.T) {
+	suite.Run(t, new(RecorderSuite))
+}
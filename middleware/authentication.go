@@ -1,27 +1,203 @@
 package middleware
 
 import (
+	"context"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
-// Authentication through bearer token
+type tokenCtxKey int
+
+const contextTokenKey tokenCtxKey = iota
+
+type (
+	// TokenSource provides a token for the request
+	TokenSource interface {
+		Token(ctx context.Context) (string, error)
+	}
+
+	// TokenSourceFn callback as a TokenSource
+	TokenSourceFn func(ctx context.Context) (string, error)
+
+	staticTokenSource string
+
+	envTokenSource string
+
+	contextTokenSource struct{}
+
+	fileTokenSource struct {
+		path string
+
+		mu      sync.Mutex
+		token   string
+		modTime time.Time
+		size    int64
+	}
+)
+
+// Token calls the callback
+func (fn TokenSourceFn) Token(ctx context.Context) (string, error) {
+	return fn(ctx)
+}
+
+// StaticTokenSource returns the same token for each request
+func StaticTokenSource(token string) TokenSource {
+	return staticTokenSource(token)
+}
+
+func (s staticTokenSource) Token(context.Context) (string, error) {
+	return string(s), nil
+}
+
+// EnvTokenSource reads the token from the environment variable on each request
+func EnvTokenSource(name string) TokenSource {
+	return envTokenSource(name)
+}
+
+func (s envTokenSource) Token(context.Context) (string, error) {
+	return os.Getenv(string(s)), nil
+}
+
+// ContextTokenSource forwards the token stored by ContextWithToken, e.g. the inbound user token
+func ContextTokenSource() TokenSource {
+	return contextTokenSource{}
+}
+
+func (contextTokenSource) Token(ctx context.Context) (string, error) {
+	return TokenFromContext(ctx), nil
+}
+
+// ContextWithToken stores the token for ContextTokenSource
+func ContextWithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, contextTokenKey, token)
+}
+
+// TokenFromContext returns the token stored by ContextWithToken
+func TokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(contextTokenKey).(string)
+	return token
+}
+
+// FileTokenSource reads the token from the file, the file is re-read when it changes on disk (e.g. mounted secret)
+func FileTokenSource(path string) TokenSource {
+	return &fileTokenSource{path: path}
+}
+
+func (s *fileTokenSource) Token(context.Context) (string, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.token, nil
+	}
+
+	content, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return "", err
+	}
+
+	s.token = strings.TrimSpace(string(content))
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+
+	return s.token, nil
+}
+
+// Authentication through bearer token, zero value (or nil source) does not authenticate the requests
 type Authentication struct {
-	token string
+	source     TokenSource
+	scheme     string
+	header     string
+	queryParam string
 }
 
+// NewAuthentication static bearer token
 func NewAuthentication(token string) *Authentication {
-	return &Authentication{token: token}
+	return NewAuthenticationWithSource(StaticTokenSource(token))
+}
+
+// NewAuthenticationWithSource bearer token from the source.
+// Header is not set if the source returns an empty token.
+func NewAuthenticationWithSource(source TokenSource) *Authentication {
+	return &Authentication{
+		source: source,
+		scheme: "Bearer",
+		header: "Authorization",
+	}
+}
+
+// WithScheme sets authentication scheme, e.g. "Token". Empty scheme passes the raw token
+func (a *Authentication) WithScheme(scheme string) *Authentication {
+	a.scheme = scheme
+	return a
+}
+
+// WithHeader sets header name, e.g. "X-Api-Key"
+func (a *Authentication) WithHeader(name string) *Authentication {
+	a.header = name
+	return a
+}
+
+// WithQueryParam passes the raw token as a query parameter instead of the header, e.g. "api_key"
+func (a *Authentication) WithQueryParam(name string) *Authentication {
+	a.queryParam = name
+	return a
 }
 
 func (a Authentication) RoundTripper(next http.RoundTripper) http.RoundTripper {
 	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
-		request = cloneRequest(request)
-		request.Header.Set(a.Header())
+		if a.source == nil {
+			return next.RoundTrip(request)
+		}
+
+		token, err := a.source.Token(request.Context())
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			return next.RoundTrip(request)
+		}
+
+		request = CloneRequest(request)
+		if a.queryParam != "" {
+			// Parameter is appended, the encoding and the order of the existing parameters are kept
+			u := *request.URL
+			if u.RawQuery != "" {
+				u.RawQuery += "&"
+			}
+			u.RawQuery += url.QueryEscape(a.queryParam) + "=" + url.QueryEscape(token)
+			request.URL = &u
+		} else {
+			request.Header.Set(a.header, a.value(token))
+		}
+
 		return next.RoundTrip(request)
 	})
 }
 
-// Header returns header name and header value
+// Header returns header name and header value, both are empty when the token is passed as a query parameter
 func (a Authentication) Header() (string, string) {
-	return "Authorization", "Bearer " + a.token
+	if a.source == nil || a.queryParam != "" {
+		return "", ""
+	}
+
+	token, _ := a.source.Token(context.Background())
+	return a.header, a.value(token)
+}
+
+func (a Authentication) value(token string) string {
+	if a.scheme == "" {
+		return token
+	}
+	return a.scheme + " " + token
 }
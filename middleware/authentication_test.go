@@ -1,8 +1,13 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -42,6 +47,94 @@ func (s *AuthorizationSuite) Test() {
 	})
 }
 
+func (s *AuthorizationSuite) TestTokenSources() {
+	var header, query, rawQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Api-Key") + r.Header.Get("Authorization")
+		query = r.URL.Query().Get("api_key")
+		rawQuery = r.URL.RawQuery
+	}))
+	defer server.Close()
+
+	do := func(middleware *Authentication, ctx context.Context) error {
+		client := &http.Client{Transport: WithMiddleware(nil, middleware)}
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"?page=1&z=%2f&a=1", nil)
+		_, err := client.Do(request.WithContext(ctx))
+		return err
+	}
+
+	s.T().Run("Context", func(t *testing.T) {
+		middleware := NewAuthenticationWithSource(ContextTokenSource())
+
+		s.NoError(do(middleware, ContextWithToken(context.Background(), "user-token")))
+		s.Equal("Bearer user-token", header)
+
+		s.NoError(do(middleware, context.Background()))
+		s.Empty(header)
+	})
+
+	s.T().Run("Environment", func(t *testing.T) {
+		os.Setenv("HTTPCLIENT_TEST_TOKEN", "env-token")
+		defer os.Unsetenv("HTTPCLIENT_TEST_TOKEN")
+
+		s.NoError(do(NewAuthenticationWithSource(EnvTokenSource("HTTPCLIENT_TEST_TOKEN")).WithScheme("Token"), context.Background()))
+		s.Equal("Token env-token", header)
+	})
+
+	s.T().Run("File", func(t *testing.T) {
+		dir, _ := ioutil.TempDir("", "httpclient")
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "token")
+
+		ioutil.WriteFile(path, []byte("file-token-1\n"), 0600)
+		middleware := NewAuthenticationWithSource(FileTokenSource(path))
+		s.NoError(do(middleware, context.Background()))
+		s.Equal("Bearer file-token-1", header)
+
+		ioutil.WriteFile(path, []byte("file-token-22\n"), 0600)
+		s.NoError(do(middleware, context.Background()))
+		s.Equal("Bearer file-token-22", header)
+
+		os.Remove(path)
+		s.Error(do(middleware, context.Background()))
+	})
+
+	s.T().Run("API key header", func(t *testing.T) {
+		middleware := NewAuthentication("key").WithHeader("X-Api-Key").WithScheme("")
+		s.NoError(do(middleware, context.Background()))
+		s.Equal("key", header)
+	})
+
+	s.T().Run("API key query parameter", func(t *testing.T) {
+		middleware := NewAuthentication("key/1").WithQueryParam("api_key")
+		s.NoError(do(middleware, context.Background()))
+		s.Equal("key/1", query)
+		s.Equal("page=1&z=%2f&a=1&api_key=key%2F1", rawQuery, "existing parameters are kept as is")
+		s.Empty(header)
+
+		name, value := middleware.Header()
+		s.Empty(name)
+		s.Empty(value)
+	})
+
+	s.T().Run("Zero value", func(t *testing.T) {
+		s.NoError(do(&Authentication{}, context.Background()))
+		s.Empty(header)
+		s.Empty(query)
+
+		name, value := Authentication{}.Header()
+		s.Empty(name)
+		s.Empty(value)
+	})
+
+	s.T().Run("Callback error", func(t *testing.T) {
+		middleware := NewAuthenticationWithSource(TokenSourceFn(func(ctx context.Context) (string, error) {
+			return "", errors.New("no token")
+		}))
+		s.Error(do(middleware, context.Background()))
+	})
+}
+
 func TestAuthorizationRunner(t *testing.T) {
 	suite.Run(t, new(AuthorizationSuite))
 }
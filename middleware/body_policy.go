@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxLoggedBodyBytes size of the logged body, the rest is replaced by the truncation marker
+const DefaultMaxLoggedBodyBytes = 64 << 10
+
+const (
+	// truncationMarker is appended to the logged body instead of the omitted bytes
+	truncationMarker = "...[truncated %d bytes]"
+	// unknownTruncationMarker is appended when the size of the omitted part is not known
+	unknownTruncationMarker = "...[truncated]"
+)
+
+type (
+	// BodyPolicy controls how the loggers capture request and response bodies.
+	// Methods of nil BodyPolicy capture the whole body.
+	BodyPolicy struct {
+		maxBytes     int
+		streaming    bool
+		contentTypes []string
+		routes       []*regexp.Regexp
+	}
+
+	// capturedBody passes the body to the reader and keeps the first bytes for the log
+	capturedBody struct {
+		io.ReadCloser
+		limit int
+		done  func(captured []byte, total int64, err error)
+
+		mu       sync.Mutex
+		captured bytes.Buffer
+		total    int64
+		once     sync.Once
+	}
+)
+
+// NewBodyPolicy logs up to DefaultMaxLoggedBodyBytes of the buffered body
+func NewBodyPolicy() *BodyPolicy {
+	return &BodyPolicy{maxBytes: DefaultMaxLoggedBodyBytes}
+}
+
+// WithMaxBytes size of the logged body, 0 logs the whole body
+func (p *BodyPolicy) WithMaxBytes(maxBytes int) *BodyPolicy {
+	p.maxBytes = maxBytes
+	return p
+}
+
+// WithStreaming the body is not read into memory by the logger. The first bytes are captured while
+// the body is read by the transport (request) or the caller (response), the record is written when
+// the body is read to the end or closed.
+func (p *BodyPolicy) WithStreaming() *BodyPolicy {
+	p.streaming = true
+	return p
+}
+
+// WithSkipContentTypes bodies of the media types are not captured, e.g. "application/pdf" or "image/*"
+func (p *BodyPolicy) WithSkipContentTypes(contentTypes ...string) *BodyPolicy {
+	for _, contentType := range contentTypes {
+		p.contentTypes = append(p.contentTypes, strings.ToLower(contentType))
+	}
+	return p
+}
+
+// WithSkipRoutes bodies of the requests and responses with matching URL path are not captured
+func (p *BodyPolicy) WithSkipRoutes(routes ...*regexp.Regexp) *BodyPolicy {
+	p.routes = append(p.routes, routes...)
+	return p
+}
+
+// skip the body capturing for the route or the content type
+func (p *BodyPolicy) skip(request *http.Request, header http.Header) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, route := range p.routes {
+		if request != nil && route.MatchString(request.URL.Path) {
+			return true
+		}
+	}
+
+	if len(p.contentTypes) == 0 {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, contentType := range p.contentTypes {
+		if contentType == mediaType ||
+			(strings.HasSuffix(contentType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(contentType, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *BodyPolicy) stream() bool {
+	return p != nil && p.streaming
+}
+
+func (p *BodyPolicy) limit() int {
+	if p == nil {
+		return 0
+	}
+	return p.maxBytes
+}
+
+// truncate the body to the logged size
+func (p *BodyPolicy) truncate(body []byte) []byte {
+	if limit := p.limit(); limit > 0 && len(body) > limit {
+		return body[:limit]
+	}
+	return body
+}
+
+// truncationMark marker for the omitted bytes, empty for the whole body, negative total is unknown size
+func truncationMark(logged int, total int64) string {
+	if total < 0 {
+		return unknownTruncationMarker
+	}
+	if total <= int64(logged) {
+		return ""
+	}
+	return fmt.Sprintf(truncationMarker, total-int64(logged))
+}
+
+// newCapturedBody keeps up to limit bytes, 0 keeps the whole body and negative limit only counts the bytes
+func newCapturedBody(body io.ReadCloser, limit int, done func(captured []byte, total int64, err error)) *capturedBody {
+	return &capturedBody{ReadCloser: body, limit: limit, done: done}
+}
+
+func (b *capturedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.mu.Lock()
+	b.total += int64(n)
+	captured := p[:n]
+	if b.limit < 0 {
+		captured = nil
+	} else if b.limit > 0 {
+		if remaining := b.limit - b.captured.Len(); remaining < len(captured) {
+			captured = captured[:remaining]
+		}
+	}
+	b.captured.Write(captured)
+	b.mu.Unlock()
+
+	if err == io.EOF {
+		b.finish(nil)
+	} else if err != nil {
+		b.finish(err)
+	}
+
+	return n, err
+}
+
+func (b *capturedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish(nil)
+	return err
+}
+
+// finish reports the captured body once
+func (b *capturedBody) finish(err error) {
+	b.once.Do(func() {
+		b.mu.Lock()
+		captured, total := append([]byte(nil), b.captured.Bytes()...), b.total
+		b.mu.Unlock()
+
+		b.done(captured, total, err)
+	})
+}
@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	log "github.com/best-expendables/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyPolicy_Skip(t *testing.T) {
+	policy := NewBodyPolicy().
+		WithSkipContentTypes("application/PDF", "image/*").
+		WithSkipRoutes(regexp.MustCompile(`^/files/`))
+
+	request, _ := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+	file, _ := http.NewRequest(http.MethodGet, "http://example.com/files/1", nil)
+
+	assert.True(t, policy.skip(request, http.Header{"Content-Type": {"application/pdf"}}))
+	assert.True(t, policy.skip(request, http.Header{"Content-Type": {"image/png; charset=binary"}}))
+	assert.True(t, policy.skip(file, http.Header{"Content-Type": {"application/json"}}))
+	assert.False(t, policy.skip(request, http.Header{"Content-Type": {"application/json"}}))
+	assert.False(t, policy.skip(request, http.Header{}))
+
+	var nilPolicy *BodyPolicy
+	assert.False(t, nilPolicy.skip(file, http.Header{"Content-Type": {"image/png"}}))
+}
+
+func TestBodyPolicy_Truncate(t *testing.T) {
+	payload := strings.Repeat("a", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	buffer := bytes.NewBufferString("")
+	logger := log.NewLoggerFactory(log.InfoLevel, log.SetOut(buffer)).Logger(context.TODO())
+	policy := NewBodyPolicy().WithMaxBytes(10)
+	client := &http.Client{
+		Transport: WithMiddleware(nil,
+			NewRequestLogger(logger).WithBodyPolicy(policy),
+			NewResponseLogger(logger).WithBodyPolicy(policy),
+		),
+	}
+
+	response, err := client.Post(server.URL, "text/plain", strings.NewReader(payload))
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, payload, string(body))
+
+	logs := buffer.String()
+	assert.Equal(t, 2, strings.Count(logs, `aaaaaaaaaa...[truncated 90 bytes]`))
+	assert.NotContains(t, logs, strings.Repeat("a", 11))
+}
+
+func TestBodyPolicy_Streaming(t *testing.T) {
+	payload := strings.Repeat("b", 100)
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = string(body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	buffer := bytes.NewBufferString("")
+	logger := log.NewLoggerFactory(log.InfoLevel, log.SetOut(buffer)).Logger(context.TODO())
+	policy := NewBodyPolicy().WithMaxBytes(10).WithStreaming()
+
+	t.Run("Request", func(t *testing.T) {
+		client := &http.Client{Transport: WithMiddleware(nil, NewRequestLogger(logger).WithBodyPolicy(policy))}
+
+		response, err := client.Post(server.URL, "text/plain", strings.NewReader(payload))
+		assert.NoError(t, err)
+		response.Body.Close()
+
+		assert.Equal(t, payload, received)
+		assert.Contains(t, buffer.String(), `bbbbbbbbbb...[truncated 90 bytes]`)
+		buffer.Reset()
+	})
+
+	t.Run("Response", func(t *testing.T) {
+		client := &http.Client{Transport: WithMiddleware(nil, NewResponseLogger(logger).WithBodyPolicy(policy))}
+
+		response, err := client.Post(server.URL, "text/plain", strings.NewReader(payload))
+		assert.NoError(t, err)
+		assert.Empty(t, buffer.String(), "response is logged when the body is read")
+
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		assert.Equal(t, payload, string(body))
+		logs := buffer.String()
+		assert.Contains(t, logs, `bbbbbbbbbb...[truncated 90 bytes]`)
+		assert.Equal(t, 1, strings.Count(logs, "ResponseLogger"), "response is logged once")
+		buffer.Reset()
+	})
+
+	t.Run("Closed without reading", func(t *testing.T) {
+		client := &http.Client{Transport: WithMiddleware(nil, NewResponseLogger(logger).WithBodyPolicy(policy))}
+
+		response, err := client.Get(server.URL)
+		assert.NoError(t, err)
+		response.Body.Close()
+
+		assert.Contains(t, buffer.String(), "ResponseLogger")
+		buffer.Reset()
+	})
+}
+
+func TestBodyPolicy_SkipBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4 document"))
+	}))
+	defer server.Close()
+
+	buffer := bytes.NewBufferString("")
+	logger := log.NewLoggerFactory(log.InfoLevel, log.SetOut(buffer)).Logger(context.TODO())
+	client := &http.Client{
+		Transport: WithMiddleware(nil,
+			NewResponseLogger(logger).WithBodyPolicy(NewBodyPolicy().WithSkipContentTypes("application/pdf")),
+		),
+	}
+
+	response, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+
+	assert.Equal(t, "%PDF-1.4 document", string(body))
+	assert.Contains(t, buffer.String(), "ResponseLogger")
+	assert.NotContains(t, buffer.String(), "document")
+}
+
+// countingReader counts the bytes read from the reader
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestBodyPolicy_BoundedRead(t *testing.T) {
+	payload := strings.Repeat("c", 100)
+	reader := &countingReader{Reader: strings.NewReader(payload)}
+
+	buffer := bytes.NewBufferString("")
+	logger := log.NewLoggerFactory(log.InfoLevel, log.SetOut(buffer)).Logger(context.TODO())
+	var readByLogger int
+	var sent string
+	transport := WithMiddleware(RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		readByLogger = reader.read
+		body, _ := ioutil.ReadAll(request.Body)
+		sent = string(body)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: request}, nil
+	}), NewRequestLogger(logger).WithBodyPolicy(NewBodyPolicy().WithMaxBytes(10)))
+
+	// Unknown content length
+	request, _ := http.NewRequest(http.MethodPost, "http://example.com", ioutil.NopCloser(reader))
+	_, err := transport.RoundTrip(request)
+	assert.NoError(t, err)
+
+	assert.True(t, readByLogger <= 11, "only the logged part of the body is read by the logger")
+	assert.Equal(t, payload, sent)
+	assert.Contains(t, buffer.String(), `cccccccccc...[truncated]`)
+}
+
+func TestBodyPolicy_BoundedReadResponse(t *testing.T) {
+	payload := strings.Repeat("c", 100)
+	reader := &countingReader{Reader: strings.NewReader(payload)}
+	body := &closeRecorder{Reader: reader}
+
+	buffer := bytes.NewBufferString("")
+	logger := log.NewLoggerFactory(log.InfoLevel, log.SetOut(buffer)).Logger(context.TODO())
+	transport := WithMiddleware(RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: body, ContentLength: -1, Request: request}, nil
+	}), NewResponseLogger(logger).WithBodyPolicy(NewBodyPolicy().WithMaxBytes(10)))
+
+	request, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	response, err := transport.RoundTrip(request)
+	assert.NoError(t, err)
+
+	assert.True(t, reader.read <= 11, "only the logged part of the body is read by the logger")
+	assert.Contains(t, buffer.String(), `cccccccccc...[truncated]`)
+
+	received, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, payload, string(received))
+	assert.NoError(t, response.Body.Close())
+	assert.True(t, body.closed, "the original body is closed")
+}
+
+// closeRecorder records closing of the body
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestBodyPolicy_StreamingKeepsRequest(t *testing.T) {
+	logger := log.NewLoggerFactory(log.InfoLevel, log.SetOut(ioutil.Discard)).Logger(context.TODO())
+	var sent *http.Request
+	transport := WithMiddleware(RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		sent = request
+		ioutil.ReadAll(request.Body)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: request}, nil
+	}), NewRequestLogger(logger).WithBodyPolicy(NewBodyPolicy().WithStreaming()))
+
+	body := ioutil.NopCloser(strings.NewReader("payload"))
+	request, _ := http.NewRequest(http.MethodPost, "http://example.com", body)
+	_, err := transport.RoundTrip(request)
+	assert.NoError(t, err)
+
+	assert.Equal(t, body, request.Body, "body of the caller's request is not replaced")
+	assert.NotEqual(t, body, sent.Body)
+}
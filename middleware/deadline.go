@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultDeadlineHeader header with remaining budget of the request in milliseconds
+const DefaultDeadlineHeader = "X-Request-Deadline"
+
+// DeadlineFormatFn formats remaining budget into header value
+type DeadlineFormatFn func(remaining time.Duration) string
+
+// BudgetError returned when the remaining budget of the request is below the minimum
+type BudgetError struct {
+	Remaining time.Duration
+	Minimum   time.Duration
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("remaining request budget %s is below minimum %s", e.Remaining, e.Minimum)
+}
+
+// Deadline passes the context deadline to the downstream service
+type Deadline struct {
+	header  string
+	format  DeadlineFormatFn
+	margin  time.Duration
+	minimum time.Duration
+}
+
+// NewDeadline creates middleware which sets X-Request-Deadline header in milliseconds
+func NewDeadline() *Deadline {
+	return &Deadline{
+		header: DefaultDeadlineHeader,
+		format: MillisecondsDeadlineFormat,
+	}
+}
+
+// WithHeader sets header name and value format, e.g. "grpc-timeout" and GRPCTimeoutFormat
+func (d *Deadline) WithHeader(name string, format DeadlineFormatFn) *Deadline {
+	d.header = name
+	d.format = format
+	return d
+}
+
+// WithSafetyMargin subtracts margin from the remaining budget (network latency, response processing)
+func (d *Deadline) WithSafetyMargin(margin time.Duration) *Deadline {
+	d.margin = margin
+	return d
+}
+
+// WithMinimumBudget refuses to send requests with a budget below the minimum
+func (d *Deadline) WithMinimumBudget(minimum time.Duration) *Deadline {
+	d.minimum = minimum
+	return d
+}
+
+func (d *Deadline) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		deadline, ok := request.Context().Deadline()
+		if !ok {
+			return next.RoundTrip(request)
+		}
+
+		remaining := time.Until(deadline) - d.margin
+		if remaining <= 0 || remaining < d.minimum {
+			return nil, &BudgetError{Remaining: remaining, Minimum: d.minimum}
+		}
+
+		request = CloneRequest(request)
+		request.Header.Set(d.header, d.format(remaining))
+
+		return next.RoundTrip(request)
+	})
+}
+
+// MillisecondsDeadlineFormat formats budget as integer milliseconds
+func MillisecondsDeadlineFormat(remaining time.Duration) string {
+	return strconv.FormatInt(int64(remaining/time.Millisecond), 10)
+}
+
+// GRPCTimeoutFormat formats budget like "grpc-timeout" header, e.g. "250m"
+func GRPCTimeoutFormat(remaining time.Duration) string {
+	// grpc-timeout allows at most 8 digits
+	const max = 100000000
+	units := []struct {
+		unit time.Duration
+		name string
+	}{
+		{time.Nanosecond, "n"},
+		{time.Microsecond, "u"},
+		{time.Millisecond, "m"},
+		{time.Second, "S"},
+		{time.Minute, "M"},
+	}
+	for _, u := range units {
+		if value := remaining / u.unit; value < max {
+			return strconv.FormatInt(int64(value), 10) + u.name
+		}
+	}
+
+	// time.Duration can not exceed 8 digits in hours
+	return strconv.FormatInt(int64(remaining/time.Hour), 10) + "H"
+}
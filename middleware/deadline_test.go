@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadline(t *testing.T) {
+	a := assert.New(t)
+
+	var header string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(DefaultDeadlineHeader)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{
+		Transport: WithMiddleware(nil, NewDeadline().WithSafetyMargin(100*time.Millisecond)),
+	}
+
+	t.Run("Without deadline", func(t *testing.T) {
+		_, err := client.Get(srv.URL)
+		a.NoError(err)
+		a.Empty(header)
+	})
+
+	t.Run("With deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		request, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		_, err := client.Do(request.WithContext(ctx))
+		a.NoError(err)
+
+		ms, err := strconv.Atoi(header)
+		a.NoError(err)
+		a.True(ms > 800 && ms <= 900, "unexpected budget %d", ms)
+		a.Empty(request.Header.Get(DefaultDeadlineHeader))
+	})
+}
+
+func TestDeadline_MinimumBudget(t *testing.T) {
+	a := assert.New(t)
+
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	transport := WithMiddleware(nil, NewDeadline().WithMinimumBudget(500*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	request, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	_, err := transport.RoundTrip(request.WithContext(ctx))
+
+	a.IsType(&BudgetError{}, err)
+	a.Equal(500*time.Millisecond, err.(*BudgetError).Minimum)
+	a.False(called)
+}
+
+func TestGRPCTimeoutFormat(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal("1000000n", GRPCTimeoutFormat(time.Millisecond))
+	a.Equal("2000000u", GRPCTimeoutFormat(2*time.Second))
+	a.Equal("300000m", GRPCTimeoutFormat(5*time.Minute))
+	a.Equal("2562047H", GRPCTimeoutFormat(time.Duration(1<<63-1)))
+}
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/best-expendables/httpclient/net"
+	"github.com/best-expendables/httpclient/net/profile"
+	log "github.com/best-expendables/logger"
+	"github.com/best-expendables/trace"
+)
+
+// StatusLevelFn log level of the response by status code
+type StatusLevelFn func(statusCode int) log.Level
+
+// DefaultStatusLevel info for successful and 422 (validation error) responses, error for the others
+func DefaultStatusLevel(statusCode int) log.Level {
+	if statusCode < 400 || statusCode == http.StatusUnprocessableEntity {
+		return log.InfoLevel
+	}
+	return log.ErrorLevel
+}
+
+type (
+	// ExchangeLogger writes a single record per call with the request, the response and the latency.
+	// The record is written when the response body is read to the end or closed, so the duration
+	// and the byte counts include the body transfer.
+	ExchangeLogger struct {
+		logger
+		redactor    *Redactor
+		keyFn       profile.KeyFunc
+		statusLevel StatusLevelFn
+		policy      LogPolicy
+	}
+
+	// countingBody counts the bytes read by the transport
+	countingBody struct {
+		io.ReadCloser
+		count int64
+	}
+)
+
+// NewExchangeLogger create logger for the request-response exchange
+func NewExchangeLogger(loggerEntry log.Entry) *ExchangeLogger {
+	return &ExchangeLogger{
+		logger:      logger{logger: loggerEntry},
+		redactor:    DefaultRedactor(),
+		keyFn:       routeKey,
+		statusLevel: DefaultStatusLevel,
+	}
+}
+
+// WithRedactor masks sensitive data in the logged URL, DefaultRedactor is used by default, nil disables masking
+func (l *ExchangeLogger) WithRedactor(redactor *Redactor) *ExchangeLogger {
+	l.redactor = redactor
+	return l
+}
+
+// WithKeyFunc route of the request, method with host and path by default
+func (l *ExchangeLogger) WithKeyFunc(fn profile.KeyFunc) *ExchangeLogger {
+	l.keyFn = fn
+	return l
+}
+
+// WithStatusLevel log level by the response status, transport errors are always logged with error level
+func (l *ExchangeLogger) WithStatusLevel(fn StatusLevelFn) *ExchangeLogger {
+	l.statusLevel = fn
+	return l
+}
+
+// WithPolicy logs only the calls allowed by the policy, nil logs every call.
+// The policy is evaluated when the response body is read to the end or closed.
+func (l *ExchangeLogger) WithPolicy(policy LogPolicy) *ExchangeLogger {
+	l.policy = policy
+	return l
+}
+
+func (l *ExchangeLogger) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		logger := l.logger.get(request.Context())
+		if logger == nil {
+			return next.RoundTrip(request)
+		}
+
+		var requestBody *countingBody
+		if request.Body != nil && request.Body != http.NoBody {
+			requestBody = &countingBody{ReadCloser: request.Body}
+			request = CloneRequest(request)
+			request.Body = requestBody
+		}
+
+		start := time.Now()
+		response, err := next.RoundTrip(request)
+		responseTime := time.Since(start)
+
+		meta := l.fields(request, response)
+		meta["response_time"] = toMilliseconds(responseTime)
+
+		if err != nil {
+			// Abandoned request (e.g. the losing hedging attempt) has not failed
+			if net.IsAbandoned(request.Context()) || !shouldLog(l.policy, &LogCall{Request: request, Err: err, Duration: responseTime}) {
+				return response, err
+			}
+			meta["err"] = err.Error()
+			meta["error_class"] = net.ClassifyRequestError(request.Context(), err)
+			meta["duration"] = toMilliseconds(responseTime)
+			meta["request_bytes"] = requestBody.bytes()
+			if report := profile.ReportFromContext(request.Context()); report != nil {
+				meta["network"] = partialNetworkFields(report)
+			}
+			logger.WithFields(meta).Error("Request has failed")
+
+			return response, err
+		}
+
+		if response.Body == nil {
+			if !shouldLog(l.policy, &LogCall{Request: request, Response: response, Duration: responseTime}) {
+				return response, nil
+			}
+			meta["duration"] = toMilliseconds(responseTime)
+			meta["request_bytes"] = requestBody.bytes()
+			l.write(logger, meta, response.StatusCode)
+
+			return response, nil
+		}
+
+		response.Body = newCapturedBody(response.Body, -1, func(_ []byte, total int64, err error) {
+			duration := time.Since(start)
+			if !shouldLog(l.policy, &LogCall{Request: request, Response: response, Err: err, Duration: duration}) {
+				return
+			}
+			meta["duration"] = toMilliseconds(duration)
+			meta["request_bytes"] = requestBody.bytes()
+			meta["response_bytes"] = total
+			if err != nil {
+				meta["err"] = err.Error()
+				meta["error_class"] = net.ClassifyRequestError(request.Context(), err)
+				logger.WithFields(meta).Error("Response body has not been read")
+
+				return
+			}
+			if report := profile.ReportFromResponse(response); report != nil {
+				meta["network"] = networkFields(report)
+			}
+			l.write(logger, meta, response.StatusCode)
+		})
+
+		return response, nil
+	})
+}
+
+func (l *ExchangeLogger) fields(request *http.Request, response *http.Response) log.Fields {
+	// response.Request is the attempt which has served the response, e.g. for hedged requests
+	if response != nil && response.Request != nil {
+		request = response.Request
+	}
+
+	attempt := HedgeAttemptFromContext(request.Context())
+	if attempt == 0 {
+		attempt = 1
+	}
+
+	meta := log.Fields{
+		"source":  "ExchangeLogger",
+		"method":  request.Method,
+		"route":   l.keyFn(request),
+		"url":     l.redactor.URL(request.URL),
+		"attempt": attempt,
+	}
+
+	if requestID := trace.RequestIDFromContext(request.Context()); requestID != "" {
+		meta["request_id"] = requestID
+	} else if requestID := trace.RequestIDFromHeader(request.Header); requestID != "" {
+		meta["request_id"] = requestID
+	}
+
+	if response != nil {
+		meta["status"] = response.StatusCode
+	}
+
+	return meta
+}
+
+func (l *ExchangeLogger) write(logger log.Entry, meta log.Fields, statusCode int) {
+	logWithLevel(logger.WithFields(meta), l.statusLevel(statusCode), http.StatusText(statusCode))
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.count, int64(n))
+	return n, err
+}
+
+// bytes read from the body, 0 for request without body
+func (b *countingBody) bytes() int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.count)
+}
+
+func toMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	log "github.com/best-expendables/logger"
+	"github.com/best-expendables/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchangeLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		ioutil.ReadAll(r.Body)
+		w.Write([]byte("response body"))
+	}))
+	defer server.Close()
+
+	buffer := bytes.NewBufferString("")
+	logger := log.NewLoggerFactory(log.DebugLevel, log.SetOut(buffer)).Logger(context.TODO())
+	client := &http.Client{
+		Transport: WithMiddleware(nil, NewNetworkProfiler(), NewExchangeLogger(logger)),
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		defer buffer.Reset()
+
+		ctx := trace.ContextWithRequestID(context.TODO(), "request-1")
+		request, _ := http.NewRequest(http.MethodPost, server.URL+"/users?token=secret", strings.NewReader("request"))
+		response, err := client.Do(request.WithContext(ctx))
+		assert.NoError(t, err)
+		assert.Empty(t, buffer.String(), "exchange is logged when the body is read")
+
+		ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		record, level := exchangeRecord(t, buffer)
+		assert.Equal(t, log.InfoLevel.String(), level)
+		assert.Equal(t, "POST", record["method"])
+		assert.Equal(t, "POST "+strings.TrimPrefix(server.URL, "http://")+"/users", record["route"])
+		assert.Equal(t, server.URL+"/users?token=[REDACTED]", record["url"])
+		assert.Equal(t, float64(200), record["status"])
+		assert.Equal(t, float64(1), record["attempt"])
+		assert.Equal(t, float64(len("request")), record["request_bytes"])
+		assert.Equal(t, float64(len("response body")), record["response_bytes"])
+		assert.Equal(t, "request-1", record["request_id"])
+		assert.Contains(t, record, "duration")
+		assert.Contains(t, record, "response_time")
+		assert.Contains(t, record, "network")
+	})
+
+	t.Run("Status level", func(t *testing.T) {
+		defer buffer.Reset()
+
+		response, err := client.Get(server.URL + "/missing")
+		assert.NoError(t, err)
+		response.Body.Close()
+
+		_, level := exchangeRecord(t, buffer)
+		assert.Equal(t, log.ErrorLevel.String(), level)
+	})
+
+	t.Run("Custom status level", func(t *testing.T) {
+		defer buffer.Reset()
+
+		client := &http.Client{
+			Transport: WithMiddleware(nil, NewExchangeLogger(logger).WithStatusLevel(func(statusCode int) log.Level {
+				if statusCode == http.StatusNotFound {
+					return log.WarningLevel
+				}
+				return DefaultStatusLevel(statusCode)
+			})),
+		}
+
+		response, err := client.Get(server.URL + "/missing")
+		assert.NoError(t, err)
+		response.Body.Close()
+
+		_, level := exchangeRecord(t, buffer)
+		assert.Equal(t, log.WarningLevel.String(), level)
+	})
+
+	t.Run("Transport error", func(t *testing.T) {
+		defer buffer.Reset()
+
+		failing := RoundTripperFn(func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})
+		client := &http.Client{Transport: WithMiddleware(failing, NewExchangeLogger(logger))}
+
+		_, err := client.Get(server.URL)
+		assert.Error(t, err)
+
+		record, level := exchangeRecord(t, buffer)
+		assert.Equal(t, log.ErrorLevel.String(), level)
+		assert.Equal(t, "other", record["error_class"])
+		assert.NotContains(t, record, "status")
+	})
+}
+
+func TestDefaultStatusLevel(t *testing.T) {
+	assert.Equal(t, log.InfoLevel, DefaultStatusLevel(http.StatusOK))
+	assert.Equal(t, log.InfoLevel, DefaultStatusLevel(http.StatusUnprocessableEntity))
+	assert.Equal(t, log.ErrorLevel, DefaultStatusLevel(http.StatusBadRequest))
+	assert.Equal(t, log.ErrorLevel, DefaultStatusLevel(http.StatusBadGateway))
+}
+
+// exchangeRecord fields and level of the single logged record
+func exchangeRecord(t *testing.T, buffer *bytes.Buffer) (map[string]interface{}, string) {
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Len(t, lines, 1)
+
+	var record struct {
+		Level   string                 `json:"level"`
+		Content map[string]interface{} `json:"content"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	return record.Content, record.Level
+}
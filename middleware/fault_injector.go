@@ -0,0 +1,360 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type faultCtxKey int
+
+const (
+	forcedFaultsKey faultCtxKey = iota
+	noFaultsKey
+)
+
+type (
+	// FaultInjector injects failures into the matching requests, for rehearsing dependency failures.
+	// Rules can be replaced (SetRules), toggled (Enable, Disable) and forced per request (ContextWithFaults) at runtime.
+	FaultInjector struct {
+		mu       sync.RWMutex
+		rules    []*FaultRule
+		disabled map[string]bool
+		enabled  bool
+	}
+
+	// FaultRule matches the requests and describes the fault, the first matching rule is applied.
+	// Latency is injected before the other faults, the request is not sent for errors, timeouts and status codes.
+	FaultRule struct {
+		name        string
+		host        string
+		routes      []*regexp.Regexp
+		header      string
+		headerValue string
+		probability float64
+
+		latency       time.Duration
+		err           error
+		timeout       bool
+		statusCode    int
+		truncateAfter int64
+	}
+
+	// FaultError returned for injected errors and timeouts, implements net.Error
+	FaultError struct {
+		Rule string
+		Err  error
+	}
+
+	// truncatedBody fails with io.ErrUnexpectedEOF after the limit
+	truncatedBody struct {
+		io.ReadCloser
+		remaining int64
+	}
+)
+
+// NewFaultInjector creates enabled middleware with the rules
+func NewFaultInjector(rules ...*FaultRule) *FaultInjector {
+	return &FaultInjector{
+		rules:    rules,
+		disabled: make(map[string]bool),
+		enabled:  true,
+	}
+}
+
+// SetRules replaces the rules, e.g. on config reload
+func (f *FaultInjector) SetRules(rules ...*FaultRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rules = rules
+}
+
+// SetEnabled turns the injection of all the rules on or off, ContextWithFaults still forces the rules
+func (f *FaultInjector) SetEnabled(enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.enabled = enabled
+}
+
+// Enable enables the rule with the name
+func (f *FaultInjector) Enable(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.disabled, name)
+}
+
+// Disable disables the rule with the name, ContextWithFaults still forces the rule
+func (f *FaultInjector) Disable(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.disabled[name] = true
+}
+
+// ContextWithFaults forces the rules with the names for requests with the context,
+// the probability, SetEnabled and Disable are ignored, request matching still applies
+func ContextWithFaults(ctx context.Context, names ...string) context.Context {
+	forced := make(map[string]bool, len(names))
+	for name := range forcedFaults(ctx) {
+		forced[name] = true
+	}
+	for _, name := range names {
+		forced[name] = true
+	}
+	return context.WithValue(ctx, forcedFaultsKey, forced)
+}
+
+// ContextWithoutFaults disables the fault injection for requests with the context
+func ContextWithoutFaults(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noFaultsKey, true)
+}
+
+func forcedFaults(ctx context.Context) map[string]bool {
+	forced, _ := ctx.Value(forcedFaultsKey).(map[string]bool)
+	return forced
+}
+
+func (f *FaultInjector) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		rule := f.match(request)
+		if rule == nil {
+			return next.RoundTrip(request)
+		}
+		return rule.inject(next, request)
+	})
+}
+
+// match the first matching rule which is enabled or forced by the context
+func (f *FaultInjector) match(request *http.Request) *FaultRule {
+	ctx := request.Context()
+	if disabled, _ := ctx.Value(noFaultsKey).(bool); disabled {
+		return nil
+	}
+	forced := forcedFaults(ctx)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, rule := range f.rules {
+		if !rule.matches(request) {
+			continue
+		}
+		if forced[rule.name] {
+			return rule
+		}
+		if f.enabled && !f.disabled[rule.name] && rand.Float64() < rule.probability {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// NewFaultRule creates rule matching all the requests with probability 1, the name is used for toggling
+func NewFaultRule(name string) *FaultRule {
+	return &FaultRule{
+		name:          name,
+		probability:   1,
+		truncateAfter: -1,
+	}
+}
+
+// Name of the rule
+func (r *FaultRule) Name() string {
+	return r.name
+}
+
+// ForHost matches the request host, with or without port
+func (r *FaultRule) ForHost(host string) *FaultRule {
+	r.host = host
+	return r
+}
+
+// ForRoutes matches the URL path with any of the regexps
+func (r *FaultRule) ForRoutes(routes ...*regexp.Regexp) *FaultRule {
+	r.routes = append(r.routes, routes...)
+	return r
+}
+
+// ForHeader matches the request header value, empty value matches any non-empty header
+func (r *FaultRule) ForHeader(name, value string) *FaultRule {
+	r.header = name
+	r.headerValue = value
+	return r
+}
+
+// WithProbability part of the matching requests with the fault, from 0 to 1
+func (r *FaultRule) WithProbability(probability float64) *FaultRule {
+	r.probability = probability
+	return r
+}
+
+// WithLatency delays the request, context cancellation interrupts the delay
+func (r *FaultRule) WithLatency(latency time.Duration) *FaultRule {
+	r.latency = latency
+	return r
+}
+
+// WithError fails the request with the error wrapped into FaultError
+func (r *FaultRule) WithError(err error) *FaultRule {
+	r.err = err
+	return r
+}
+
+// WithConnectionError fails the request with "connection refused" dial error
+func (r *FaultRule) WithConnectionError() *FaultRule {
+	return r.WithError(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+}
+
+// WithTimeout fails the request with the timeout error (after the latency, if any)
+func (r *FaultRule) WithTimeout() *FaultRule {
+	r.timeout = true
+	return r
+}
+
+// WithStatus responds with the synthetic status code, the request is not sent
+func (r *FaultRule) WithStatus(statusCode int) *FaultRule {
+	r.statusCode = statusCode
+	return r
+}
+
+// WithTruncatedBody the response body fails with io.ErrUnexpectedEOF after the bytes
+func (r *FaultRule) WithTruncatedBody(bytes int64) *FaultRule {
+	r.truncateAfter = bytes
+	return r
+}
+
+func (r *FaultRule) matches(request *http.Request) bool {
+	if r.host != "" && !strings.EqualFold(r.host, request.URL.Host) && !strings.EqualFold(r.host, request.URL.Hostname()) {
+		return false
+	}
+
+	if len(r.routes) > 0 {
+		matched := false
+		for _, route := range r.routes {
+			if route.MatchString(request.URL.Path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if r.header != "" {
+		value := request.Header.Get(r.header)
+		if value == "" || (r.headerValue != "" && value != r.headerValue) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *FaultRule) inject(next http.RoundTripper, request *http.Request) (*http.Response, error) {
+	if r.latency > 0 {
+		timer := time.NewTimer(r.latency)
+		select {
+		case <-timer.C:
+		case <-request.Context().Done():
+			timer.Stop()
+			closeRequestBody(request)
+			return nil, request.Context().Err()
+		}
+	}
+
+	switch {
+	case r.timeout:
+		closeRequestBody(request)
+		return nil, &FaultError{Rule: r.name, Err: errFaultTimeout}
+	case r.err != nil:
+		closeRequestBody(request)
+		return nil, &FaultError{Rule: r.name, Err: r.err}
+	case r.statusCode > 0:
+		closeRequestBody(request)
+		body := fmt.Sprintf("fault injected by rule %q", r.name)
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", r.statusCode, http.StatusText(r.statusCode)),
+			StatusCode:    r.statusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			Body:          ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			ContentLength: int64(len(body)),
+			Request:       request,
+		}, nil
+	}
+
+	response, err := next.RoundTrip(request)
+	if err != nil || r.truncateAfter < 0 {
+		return response, err
+	}
+
+	response.Body = &truncatedBody{ReadCloser: response.Body, remaining: r.truncateAfter}
+	response.ContentLength = -1
+	return response, nil
+}
+
+// closeRequestBody closes the body of the request which is not sent, as the transport would
+func closeRequestBody(request *http.Request) {
+	if request.Body != nil {
+		request.Body.Close()
+	}
+}
+
+// errFaultTimeout injected timeout
+var errFaultTimeout error = faultTimeoutError{}
+
+type faultTimeoutError struct{}
+
+func (faultTimeoutError) Error() string   { return "i/o timeout" }
+func (faultTimeoutError) Timeout() bool   { return true }
+func (faultTimeoutError) Temporary() bool { return true }
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("fault injected by rule %q: %s", e.Rule, e.Err)
+}
+
+func (e *FaultError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout whether the injected error is a timeout
+func (e *FaultError) Timeout() bool {
+	timeout, ok := e.Err.(interface{ Timeout() bool })
+	return ok && timeout.Timeout()
+}
+
+// Temporary whether the injected error is temporary
+func (e *FaultError) Temporary() bool {
+	temporary, ok := e.Err.(interface{ Temporary() bool })
+	return ok && temporary.Temporary()
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/best-expendables/httpclient/net"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultInjector(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte("0123456789"))
+	}))
+	defer srv.Close()
+
+	get := func(injector *FaultInjector, ctx context.Context, path string) (*http.Response, error) {
+		client := &http.Client{Transport: WithMiddleware(nil, injector)}
+		request, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		request.Header.Set("X-Chaos", "on")
+		return client.Do(request.WithContext(ctx))
+	}
+
+	t.Run("Status", func(t *testing.T) {
+		a := assert.New(t)
+		atomic.StoreInt32(&hits, 0)
+		injector := NewFaultInjector(NewFaultRule("unavailable").ForRoutes(regexp.MustCompile("^/users")).WithStatus(http.StatusServiceUnavailable))
+
+		response, err := get(injector, context.Background(), "/users/1")
+		a.NoError(err)
+		a.Equal(http.StatusServiceUnavailable, response.StatusCode)
+		a.EqualValues(0, atomic.LoadInt32(&hits), "request is not sent")
+
+		response, err = get(injector, context.Background(), "/orders")
+		a.NoError(err)
+		a.Equal(http.StatusOK, response.StatusCode)
+	})
+
+	t.Run("Connection error", func(t *testing.T) {
+		a := assert.New(t)
+		injector := NewFaultInjector(NewFaultRule("refused").ForHost("127.0.0.1").WithConnectionError())
+
+		_, err := get(injector, context.Background(), "/")
+		var faultErr *FaultError
+		a.True(errors.As(err, &faultErr))
+		a.Equal("refused", faultErr.Rule)
+		a.Equal(net.ErrorClassConnect, net.ClassifyError(err))
+	})
+
+	t.Run("Latency and timeout", func(t *testing.T) {
+		a := assert.New(t)
+		injector := NewFaultInjector(NewFaultRule("slow").ForHeader("X-Chaos", "on").WithLatency(50 * time.Millisecond).WithTimeout())
+
+		start := time.Now()
+		_, err := get(injector, context.Background(), "/")
+		a.True(time.Since(start) >= 50*time.Millisecond)
+		a.Equal(net.ErrorClassTimeout, net.ClassifyError(err))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = get(injector, ctx, "/")
+		a.True(errors.Is(err, context.DeadlineExceeded), "context interrupts the latency")
+	})
+
+	t.Run("Truncated body", func(t *testing.T) {
+		a := assert.New(t)
+		injector := NewFaultInjector(NewFaultRule("truncated").WithTruncatedBody(4))
+
+		response, err := get(injector, context.Background(), "/")
+		a.NoError(err)
+		body, err := ioutil.ReadAll(response.Body)
+		a.Equal(io.ErrUnexpectedEOF, err)
+		a.Equal("0123", string(body))
+	})
+
+	t.Run("Runtime toggles", func(t *testing.T) {
+		a := assert.New(t)
+		injector := NewFaultInjector(NewFaultRule("teapot").WithStatus(http.StatusTeapot).WithProbability(0))
+
+		response, _ := get(injector, context.Background(), "/")
+		a.Equal(http.StatusOK, response.StatusCode, "probability 0")
+
+		response, _ = get(injector, ContextWithFaults(context.Background(), "teapot"), "/")
+		a.Equal(http.StatusTeapot, response.StatusCode, "forced by the context")
+
+		injector.SetRules(NewFaultRule("teapot").WithStatus(http.StatusTeapot))
+		response, _ = get(injector, context.Background(), "/")
+		a.Equal(http.StatusTeapot, response.StatusCode, "rules reloaded")
+
+		response, _ = get(injector, ContextWithoutFaults(context.Background()), "/")
+		a.Equal(http.StatusOK, response.StatusCode, "disabled by the context")
+
+		injector.Disable("teapot")
+		response, _ = get(injector, context.Background(), "/")
+		a.Equal(http.StatusOK, response.StatusCode, "rule disabled")
+
+		injector.Enable("teapot")
+		injector.SetEnabled(false)
+		response, _ = get(injector, context.Background(), "/")
+		a.Equal(http.StatusOK, response.StatusCode, "injector disabled")
+
+		response, _ = get(injector, ContextWithFaults(context.Background(), "teapot"), "/")
+		a.Equal(http.StatusTeapot, response.StatusCode, "forced while disabled")
+	})
+}
+
+// closeTrackingBody records whether the body was closed
+type closeTrackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeTrackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestFaultInjector_ClosesRequestBody(t *testing.T) {
+	next := RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		t.Fatal("request is not sent")
+		return nil, nil
+	})
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for name, test := range map[string]struct {
+		rule *FaultRule
+		ctx  context.Context
+	}{
+		"Status":          {rule: NewFaultRule("status").WithStatus(http.StatusServiceUnavailable), ctx: context.Background()},
+		"Error":           {rule: NewFaultRule("error").WithConnectionError(), ctx: context.Background()},
+		"Timeout":         {rule: NewFaultRule("timeout").WithTimeout(), ctx: context.Background()},
+		"Latency cancels": {rule: NewFaultRule("slow").WithLatency(time.Minute).WithStatus(http.StatusOK), ctx: canceled},
+	} {
+		t.Run(name, func(t *testing.T) {
+			body := &closeTrackingBody{Reader: strings.NewReader("payload")}
+			request, _ := http.NewRequest(http.MethodPost, "http://example.com", body)
+
+			NewFaultInjector(test.rule).RoundTripper(next).RoundTrip(request.WithContext(test.ctx))
+			assert.True(t, body.closed)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package har
+
+import "time"
+
+// Version of the HAR format
+const Version = "1.2"
+
+type (
+	// HAR 1.2 document, see http://www.softwareishard.com/blog/har-12-spec/
+	HAR struct {
+		Log Log `json:"log"`
+	}
+
+	// Log root of the exported data
+	Log struct {
+		Version string   `json:"version"`
+		Creator Creator  `json:"creator"`
+		Entries []*Entry `json:"entries"`
+	}
+
+	// Creator application which has recorded the log
+	Creator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	// Entry single exchange, Error is set for the transport errors (custom field)
+	Entry struct {
+		StartedDateTime time.Time `json:"startedDateTime"`
+		// Time total time in milliseconds, sum of the timings
+		Time            float64  `json:"time"`
+		Request         Request  `json:"request"`
+		Response        Response `json:"response"`
+		Cache           Cache    `json:"cache"`
+		Timings         Timings  `json:"timings"`
+		ServerIPAddress string   `json:"serverIPAddress,omitempty"`
+		Connection      string   `json:"connection,omitempty"`
+		Error           string   `json:"_error,omitempty"`
+	}
+
+	// Request recorded request, URL and headers are redacted
+	Request struct {
+		Method      string      `json:"method"`
+		URL         string      `json:"url"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []Cookie    `json:"cookies"`
+		Headers     []NameValue `json:"headers"`
+		QueryString []NameValue `json:"queryString"`
+		PostData    *PostData   `json:"postData,omitempty"`
+		// HeadersSize -1, the size is not available
+		HeadersSize int64 `json:"headersSize"`
+		BodySize    int64 `json:"bodySize"`
+	}
+
+	// Response recorded response, Status is 0 for transport errors
+	Response struct {
+		Status      int         `json:"status"`
+		StatusText  string      `json:"statusText"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []Cookie    `json:"cookies"`
+		Headers     []NameValue `json:"headers"`
+		Content     Content     `json:"content"`
+		RedirectURL string      `json:"redirectURL"`
+		// HeadersSize -1, the size is not available
+		HeadersSize int64 `json:"headersSize"`
+		BodySize    int64 `json:"bodySize"`
+	}
+
+	// Cookie of the request or the response
+	Cookie struct {
+		Name     string     `json:"name"`
+		Value    string     `json:"value"`
+		Path     string     `json:"path,omitempty"`
+		Domain   string     `json:"domain,omitempty"`
+		Expires  *time.Time `json:"expires,omitempty"`
+		HTTPOnly bool       `json:"httpOnly,omitempty"`
+		Secure   bool       `json:"secure,omitempty"`
+	}
+
+	// NameValue header, query parameter or form field
+	NameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	// PostData request body, Params are set for the URL encoded form
+	PostData struct {
+		MimeType string      `json:"mimeType"`
+		Params   []NameValue `json:"params"`
+		Text     string      `json:"text"`
+		Comment  string      `json:"comment,omitempty"`
+	}
+
+	// Content response body, binary content is base64 encoded
+	Content struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty"`
+		Comment  string `json:"comment,omitempty"`
+	}
+
+	// Cache is not recorded
+	Cache struct{}
+
+	// Timings phases of the exchange in milliseconds, -1 if the phase does not apply (e.g. DNS for reused connection)
+	Timings struct {
+		Blocked float64 `json:"blocked"`
+		DNS     float64 `json:"dns"`
+		Connect float64 `json:"connect"`
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+		// SSL is included into Connect
+		SSL float64 `json:"ssl"`
+	}
+)
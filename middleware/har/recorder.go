@@ -0,0 +1,477 @@
+package har
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	stdnet "net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/best-expendables/httpclient/middleware"
+	"github.com/best-expendables/httpclient/net/profile"
+)
+
+const (
+	// DefaultMaxEntries number of the latest exchanges kept by the recorder
+	DefaultMaxEntries = 1000
+	// DefaultMaxBodySize recorded part of the request and response bodies
+	DefaultMaxBodySize = 1 << 20
+
+	creatorName    = "httpclient"
+	creatorVersion = "1.0"
+	formMimeType   = "application/x-www-form-urlencoded"
+)
+
+type (
+	// Recorder records exchanges into HAR, the latest entries are kept in the ring buffer.
+	// The entry is recorded when the response body is read to the end or closed.
+	Recorder struct {
+		maxEntries  int
+		maxBodySize int
+		redactor    *middleware.Redactor
+		hooks       []func(entry *Entry)
+
+		mu      sync.Mutex
+		entries []*Entry
+		next    int
+	}
+
+	// recordedBody keeps the first bytes of the response body while it is read
+	recordedBody struct {
+		io.ReadCloser
+		limit int
+		done  func(body []byte, size int64)
+
+		mu   sync.Mutex
+		body bytes.Buffer
+		size int64
+		once sync.Once
+	}
+
+	// remainingBody reads the recorded bytes first, then the rest of the original body
+	remainingBody struct {
+		io.Reader
+		io.Closer
+	}
+)
+
+// NewRecorder keeps DefaultMaxEntries exchanges, sensitive data is masked by middleware.DefaultRedactor
+func NewRecorder() *Recorder {
+	return &Recorder{
+		maxEntries:  DefaultMaxEntries,
+		maxBodySize: DefaultMaxBodySize,
+		redactor:    middleware.DefaultRedactor(),
+	}
+}
+
+// WithMaxEntries size of the ring buffer, the oldest entries are dropped
+func (r *Recorder) WithMaxEntries(maxEntries int) *Recorder {
+	r.maxEntries = maxEntries
+	return r
+}
+
+// WithMaxBodySize recorded part of the bodies, 0 records the whole body
+func (r *Recorder) WithMaxBodySize(maxBodySize int) *Recorder {
+	r.maxBodySize = maxBodySize
+	return r
+}
+
+// WithRedactor masks sensitive data in URL, headers and bodies, nil disables masking
+func (r *Recorder) WithRedactor(redactor *middleware.Redactor) *Recorder {
+	r.redactor = redactor
+	return r
+}
+
+// WithHook modifies the entry (e.g. removes custom secrets) before it is recorded
+func (r *Recorder) WithHook(hook func(entry *Entry)) *Recorder {
+	r.hooks = append(r.hooks, hook)
+	return r
+}
+
+func (r *Recorder) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return middleware.RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		if profile.ReportFromContext(request.Context()) == nil {
+			request = profile.Observe(request)
+		}
+
+		var body []byte
+		var size int64
+		if request.Body != nil && request.Body != http.NoBody {
+			var err error
+			if request, body, size, err = r.readRequestBody(request); err != nil {
+				return nil, err
+			}
+		}
+
+		entry := &Entry{
+			StartedDateTime: time.Now(),
+			Request:         r.request(request, body, size),
+		}
+
+		response, err := next.RoundTrip(request)
+		headersDone := time.Now()
+
+		if err != nil {
+			entry.Error = err.Error()
+			entry.Response = Response{
+				Cookies:     []Cookie{},
+				Headers:     []NameValue{},
+				Content:     Content{MimeType: "x-unknown"},
+				HeadersSize: -1,
+				BodySize:    -1,
+			}
+			r.finish(entry, profile.ReportFromContext(request.Context()), headersDone, headersDone)
+
+			return response, err
+		}
+
+		if response.Body == nil {
+			entry.Response = r.response(response, nil, 0)
+			r.finish(entry, profile.ReportFromResponse(response), headersDone, headersDone)
+
+			return response, nil
+		}
+
+		response.Body = &recordedBody{ReadCloser: response.Body, limit: r.maxBodySize, done: func(body []byte, size int64) {
+			entry.Response = r.response(response, body, size)
+			r.finish(entry, profile.ReportFromResponse(response), headersDone, time.Now())
+		}}
+
+		return response, nil
+	})
+}
+
+// Entries recorded exchanges from the oldest
+func (r *Recorder) Entries() []*Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]*Entry, 0, len(r.entries))
+	entries = append(entries, r.entries[r.next:]...)
+	return append(entries, r.entries[:r.next]...)
+}
+
+// HAR document with the recorded exchanges
+func (r *Recorder) HAR() *HAR {
+	return &HAR{Log: Log{
+		Version: Version,
+		Creator: Creator{Name: creatorName, Version: creatorVersion},
+		Entries: r.Entries(),
+	}}
+}
+
+// WriteTo writes HAR document as JSON, e.g. into bytes.Buffer
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// SaveFile writes HAR document into the file, which can be opened by browser devtools
+func (r *Recorder) SaveFile(path string) error {
+	buf := bytes.Buffer{}
+	if _, err := r.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// Reset drops the recorded entries
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = nil
+	r.next = 0
+}
+
+// readRequestBody reads up to maxBodySize bytes of the body, the returned clone of the request sends the whole body.
+// The body is closed on error.
+// Size is -1 when the body is larger than maxBodySize and its length is unknown.
+func (r *Recorder) readRequestBody(request *http.Request) (*http.Request, []byte, int64, error) {
+	reader := io.Reader(request.Body)
+	if r.maxBodySize > 0 {
+		// Only the recorded part of the body is read into memory
+		reader = io.LimitReader(request.Body, int64(r.maxBodySize)+1)
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		request.Body.Close()
+		return nil, nil, 0, err
+	}
+
+	clone := *request
+	size := int64(len(body))
+	if r.maxBodySize > 0 && len(body) > r.maxBodySize {
+		clone.Body = &remainingBody{Reader: io.MultiReader(bytes.NewReader(body), request.Body), Closer: request.Body}
+		size = request.ContentLength
+		if size <= int64(r.maxBodySize) {
+			size = -1
+		}
+		return &clone, body[:r.maxBodySize], size, nil
+	}
+
+	request.Body.Close()
+	clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return &clone, body, size, nil
+}
+
+func (r *Recorder) request(request *http.Request, body []byte, size int64) Request {
+	redactedURL := r.redactor.URL(request.URL)
+
+	recorded := Request{
+		Method:      request.Method,
+		URL:         redactedURL,
+		HTTPVersion: httpVersion(request.Proto),
+		Cookies:     cookies((&http.Request{Header: r.redactor.Header(request.Header)}).Cookies()),
+		Headers:     nameValues(r.redactor.Header(request.Header)),
+		QueryString: []NameValue{},
+		HeadersSize: -1,
+		BodySize:    size,
+	}
+
+	if u, err := url.Parse(redactedURL); err == nil {
+		if query, err := url.ParseQuery(u.RawQuery); err == nil {
+			recorded.QueryString = nameValues(query)
+		}
+	}
+
+	if body == nil {
+		return recorded
+	}
+
+	mimeType := request.Header.Get("Content-Type")
+	text, _, comment := r.text(body, size)
+	recorded.PostData = &PostData{MimeType: mimeType, Params: []NameValue{}, Text: text, Comment: comment}
+
+	if mediaType, _, _ := mime.ParseMediaType(mimeType); mediaType == formMimeType {
+		values, _ := url.ParseQuery(string(r.truncate(body)))
+		redacted := r.redactor.Form(values)
+		recorded.PostData.Params = nameValues(redacted)
+		recorded.PostData.Text = redacted.Encode()
+	}
+
+	return recorded
+}
+
+func (r *Recorder) response(response *http.Response, body []byte, size int64) Response {
+	header := r.redactor.Header(response.Header)
+
+	text, encoding, comment := r.text(body, size)
+	recorded := Response{
+		Status:      response.StatusCode,
+		StatusText:  http.StatusText(response.StatusCode),
+		HTTPVersion: httpVersion(response.Proto),
+		Cookies:     cookies((&http.Response{Header: header}).Cookies()),
+		Headers:     nameValues(header),
+		Content: Content{
+			Size:     size,
+			MimeType: response.Header.Get("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+			Comment:  comment,
+		},
+		RedirectURL: response.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    size,
+	}
+
+	if recorded.Content.MimeType == "" {
+		recorded.Content.MimeType = "x-unknown"
+	}
+
+	return recorded
+}
+
+// text of the recorded body, binary body is base64 encoded
+func (r *Recorder) text(body []byte, size int64) (text, encoding, comment string) {
+	recorded := r.truncate(body)
+	if size < 0 {
+		comment = fmt.Sprintf("truncated to %d bytes", len(recorded))
+	} else if int64(len(recorded)) < size {
+		comment = fmt.Sprintf("truncated to %d of %d bytes", len(recorded), size)
+	}
+
+	if !utf8.Valid(recorded) {
+		return base64.StdEncoding.EncodeToString(recorded), "base64", comment
+	}
+
+	return r.redactor.Body(string(recorded)), "", comment
+}
+
+func (r *Recorder) truncate(body []byte) []byte {
+	if r.maxBodySize > 0 && len(body) > r.maxBodySize {
+		return body[:r.maxBodySize]
+	}
+	return body
+}
+
+// finish calculates the timings and adds the entry into the ring buffer
+func (r *Recorder) finish(entry *Entry, report *profile.Report, headersDone, bodyDone time.Time) {
+	entry.Timings = timings(report, entry.StartedDateTime, headersDone, bodyDone)
+	for _, phase := range []float64{entry.Timings.Blocked, entry.Timings.DNS, entry.Timings.Connect,
+		entry.Timings.Send, entry.Timings.Wait, entry.Timings.Receive} {
+		if phase > 0 {
+			entry.Time += phase
+		}
+	}
+
+	if report != nil {
+		if host, _, err := stdnet.SplitHostPort(report.RemoteAddr); err == nil {
+			entry.ServerIPAddress = host
+		}
+		entry.Connection = report.LocalAddr
+	}
+
+	for _, hook := range r.hooks {
+		hook(entry)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxEntries <= 0 {
+		return
+	}
+	if len(r.entries) < r.maxEntries {
+		r.entries = append(r.entries, entry)
+		return
+	}
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+}
+
+// timings from the network profile, without the profile the whole time before headers is the wait
+func timings(report *profile.Report, start, headersDone, bodyDone time.Time) Timings {
+	t := Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Send: 0, Receive: milliseconds(bodyDone.Sub(headersDone))}
+
+	if report == nil || report.GotConn.IsZero() {
+		t.Wait = milliseconds(headersDone.Sub(start))
+		return t
+	}
+
+	blocked := report.GotConn.Sub(report.GetConn)
+	if !report.Reused {
+		if !report.DNSLookupDone.IsZero() {
+			t.DNS = milliseconds(report.DNSLookupTime())
+			blocked -= report.DNSLookupTime()
+		}
+		if !report.ConnectDone.IsZero() {
+			connect := report.ConnectionTime()
+			if !report.TLSHandshakeDone.IsZero() {
+				t.SSL = milliseconds(report.TLSHandshakeTime())
+				connect += report.TLSHandshakeTime()
+			}
+			t.Connect = milliseconds(connect)
+			blocked -= connect
+		}
+	}
+	if blocked < 0 {
+		blocked = 0
+	}
+	t.Blocked = milliseconds(blocked)
+
+	if report.WroteRequest.IsZero() {
+		t.Wait = milliseconds(headersDone.Sub(report.GotConn))
+		return t
+	}
+	t.Send = milliseconds(report.WroteRequest.Sub(report.GotConn))
+	t.Wait = milliseconds(headersDone.Sub(report.WroteRequest))
+
+	return t
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.mu.Lock()
+	b.size += int64(n)
+	recorded := p[:n]
+	if b.limit > 0 {
+		if remaining := b.limit - b.body.Len(); remaining < len(recorded) {
+			recorded = recorded[:remaining]
+		}
+	}
+	b.body.Write(recorded)
+	b.mu.Unlock()
+
+	if err != nil {
+		b.finish()
+	}
+
+	return n, err
+}
+
+func (b *recordedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *recordedBody) finish() {
+	b.once.Do(func() {
+		b.mu.Lock()
+		body, size := append([]byte{}, b.body.Bytes()...), b.size
+		b.mu.Unlock()
+
+		b.done(body, size)
+	})
+}
+
+func nameValues(values map[string][]string) []NameValue {
+	pairs := make([]NameValue, 0, len(values))
+	for _, name := range sortedKeys(values) {
+		for _, value := range values[name] {
+			pairs = append(pairs, NameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func cookies(httpCookies []*http.Cookie) []Cookie {
+	recorded := make([]Cookie, 0, len(httpCookies))
+	for _, c := range httpCookies {
+		cookie := Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			cookie.Expires = &expires
+		}
+		recorded = append(recorded, cookie)
+	}
+	return recorded
+}
+
+func httpVersion(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,247 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/best-expendables/httpclient/middleware"
+	"github.com/stretchr/testify/suite"
+)
+
+type RecorderSuite struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+func (s *RecorderSuite) SetupSuite() {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte{0xff, 0xfe, 0x00, 0x01})
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", HttpOnly: true})
+			w.Write([]byte(`{"id":1,"access_token":"secret-token"}`))
+		}
+	}))
+}
+
+func (s *RecorderSuite) TearDownSuite() {
+	s.server.Close()
+}
+
+func (s *RecorderSuite) client(recorder *Recorder) *http.Client {
+	return &http.Client{Transport: middleware.WithMiddleware(nil, recorder)}
+}
+
+func (s *RecorderSuite) TestRecord() {
+	recorder := NewRecorder()
+
+	request, _ := http.NewRequest(http.MethodPost, s.server.URL+"/users?page=2&token=query-secret",
+		strings.NewReader(url.Values{"name": {"John"}, "password": {"form-secret"}}.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "Bearer header-secret")
+
+	response, err := s.client(recorder).Do(request)
+	s.NoError(err)
+	s.Empty(recorder.Entries(), "entry is recorded when the body is read")
+
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	s.Equal(`{"id":1,"access_token":"secret-token"}`, string(body), "caller gets the original body")
+
+	entries := recorder.Entries()
+	s.Len(entries, 1)
+	entry := entries[0]
+
+	s.Equal(http.MethodPost, entry.Request.Method)
+	s.Contains(entry.Request.URL, "page=2")
+	s.Contains(entry.Request.QueryString, NameValue{Name: "page", Value: "2"})
+	s.Contains(entry.Request.QueryString, NameValue{Name: "token", Value: middleware.DefaultRedactionMask})
+	s.Contains(entry.Request.Headers, NameValue{Name: "Authorization", Value: middleware.DefaultRedactionMask})
+	s.Equal("application/x-www-form-urlencoded", entry.Request.PostData.MimeType)
+	s.Contains(entry.Request.PostData.Params, NameValue{Name: "name", Value: "John"})
+	s.Contains(entry.Request.PostData.Params, NameValue{Name: "password", Value: middleware.DefaultRedactionMask})
+
+	s.Equal(http.StatusOK, entry.Response.Status)
+	s.Equal("application/json", entry.Response.Content.MimeType)
+	s.Contains(entry.Response.Content.Text, `"access_token":"[REDACTED]"`)
+	s.Equal(int64(len(body)), entry.Response.Content.Size)
+	s.Len(entry.Response.Cookies, 0, "Set-Cookie is redacted")
+
+	s.Equal("127.0.0.1", entry.ServerIPAddress)
+	s.NotEmpty(entry.Connection)
+	s.True(entry.Timings.Wait >= 0)
+	s.True(entry.Time > 0)
+
+	buf := bytes.Buffer{}
+	_, err = recorder.WriteTo(&buf)
+	s.NoError(err)
+	s.NotContains(buf.String(), "secret")
+
+	var document HAR
+	s.NoError(json.Unmarshal(buf.Bytes(), &document))
+	s.Equal(Version, document.Log.Version)
+	s.Len(document.Log.Entries, 1)
+}
+
+func (s *RecorderSuite) TestBinaryContent() {
+	recorder := NewRecorder()
+
+	response, err := s.client(recorder).Get(s.server.URL + "/binary")
+	s.NoError(err)
+	ioutil.ReadAll(response.Body)
+	response.Body.Close()
+
+	content := recorder.Entries()[0].Response.Content
+	s.Equal("base64", content.Encoding)
+	s.Equal("//4AAQ==", content.Text)
+}
+
+func (s *RecorderSuite) TestMaxBodySize() {
+	recorder := NewRecorder().WithMaxBodySize(8).WithRedactor(nil)
+
+	response, err := s.client(recorder).Get(s.server.URL)
+	s.NoError(err)
+	ioutil.ReadAll(response.Body)
+	response.Body.Close()
+
+	content := recorder.Entries()[0].Response.Content
+	s.Equal(`{"id":1,`, content.Text)
+	s.Equal("truncated to 8 of 38 bytes", content.Comment)
+	s.Equal(int64(38), content.Size)
+}
+
+func (s *RecorderSuite) TestMaxRequestBodySize() {
+	recorder := NewRecorder().WithMaxBodySize(8).WithRedactor(nil)
+	payload := strings.Repeat("c", 100)
+	body := &requestBody{Reader: strings.NewReader(payload)}
+
+	var sent string
+	transport := middleware.WithMiddleware(middleware.RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+		s.True(body.read <= 9, "only the recorded part of the body is read by the recorder")
+		data, _ := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		sent = string(data)
+		return &http.Response{StatusCode: http.StatusNoContent, Request: r}, nil
+	}), recorder)
+
+	// Unknown content length
+	request, _ := http.NewRequest(http.MethodPost, s.server.URL, body)
+	_, err := transport.RoundTrip(request)
+	s.NoError(err)
+	s.Equal(payload, sent)
+	s.True(body.closed)
+
+	recorded := recorder.Entries()[0].Request
+	s.Equal("cccccccc", recorded.PostData.Text)
+	s.Equal("truncated to 8 bytes", recorded.PostData.Comment)
+	s.Equal(int64(-1), recorded.BodySize)
+}
+
+func (s *RecorderSuite) TestRequestBodyError() {
+	recorder := NewRecorder()
+	body := &requestBody{Reader: errorReader{err: errors.New("read failed")}}
+	transport := middleware.WithMiddleware(middleware.RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+		s.Fail("request with unreadable body is sent")
+		return nil, nil
+	}), recorder)
+
+	request, _ := http.NewRequest(http.MethodPost, s.server.URL, body)
+	_, err := transport.RoundTrip(request)
+	s.EqualError(err, "read failed")
+	s.True(body.closed, "body is closed on error")
+}
+
+func (s *RecorderSuite) TestRingBuffer() {
+	recorder := NewRecorder().WithMaxEntries(2)
+	client := s.client(recorder)
+
+	for _, path := range []string{"/1", "/2", "/3"} {
+		response, err := client.Get(s.server.URL + path)
+		s.NoError(err)
+		response.Body.Close()
+	}
+
+	entries := recorder.Entries()
+	s.Len(entries, 2)
+	s.Equal(s.server.URL+"/2", entries[0].Request.URL)
+	s.Equal(s.server.URL+"/3", entries[1].Request.URL)
+
+	recorder.Reset()
+	s.Empty(recorder.Entries())
+}
+
+func (s *RecorderSuite) TestHookAndError() {
+	recorder := NewRecorder().WithHook(func(entry *Entry) {
+		entry.Request.Headers = append(entry.Request.Headers, NameValue{Name: "X-Hook", Value: "1"})
+	})
+	failing := middleware.RoundTripperFn(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	client := &http.Client{Transport: middleware.WithMiddleware(failing, recorder)}
+
+	_, err := client.Get(s.server.URL)
+	s.Error(err)
+
+	entry := recorder.Entries()[0]
+	s.Equal("connection refused", entry.Error)
+	s.Equal(0, entry.Response.Status)
+	s.Contains(entry.Request.Headers, NameValue{Name: "X-Hook", Value: "1"})
+}
+
+func (s *RecorderSuite) TestSaveFile() {
+	recorder := NewRecorder()
+
+	response, err := s.client(recorder).Get(s.server.URL)
+	s.NoError(err)
+	response.Body.Close()
+
+	path := filepath.Join(s.T().TempDir(), "capture.har")
+	s.NoError(recorder.SaveFile(path))
+
+	data, err := ioutil.ReadFile(path)
+	s.NoError(err)
+	s.Contains(string(data), `"version": "1.2"`)
+}
+
+// requestBody counts the read bytes and records closing of the body
+type requestBody struct {
+	io.Reader
+	read   int
+	closed bool
+}
+
+func (b *requestBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.read += n
+	return n, err
+}
+
+func (b *requestBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestRecorder(t *testing.T) {
+	suite.Run(t, new(RecorderSuite))
+}
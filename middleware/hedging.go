@@ -0,0 +1,350 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/best-expendables/httpclient/net"
+	"github.com/best-expendables/httpclient/net/profile"
+)
+
+// Hedging defaults
+const (
+	DefaultHedgingMaxAttempts = 2
+	DefaultHedgingBudget      = 0.1
+	DefaultHedgingWindow      = 100
+	// DefaultHedgingMaxRoutes number of routes with latencies, the least recently used route is evicted
+	DefaultHedgingMaxRoutes = 1000
+	// hedgingMaxTokens limits burst of hedged requests after a quiet period
+	hedgingMaxTokens = 10
+	// hedgingMinSamples number of latencies required for the percentile delay
+	hedgingMinSamples = 10
+)
+
+type hedgingCtxKey int
+
+const hedgeAttemptKey hedgingCtxKey = iota
+
+type (
+	// Hedging sends the next attempt of the request if the previous one has not been answered
+	// after the delay, the first successful response is used and the other attempts are canceled.
+	// Use it only for idempotent requests against replicated services.
+	Hedging struct {
+		delay       time.Duration
+		percentile  float64
+		window      int
+		maxAttempts int
+		budget      float64
+		keyFn       profile.KeyFunc
+		methods     map[string]bool
+		maxRoutes   int
+
+		mu     sync.Mutex
+		tokens float64
+		// routes latency windows from the most recently used
+		routes    *list.List
+		latencies map[string]*list.Element
+	}
+
+	hedgeResult struct {
+		response *http.Response
+		err      error
+		cancel   context.CancelFunc
+		latency  time.Duration
+		attempt  int
+	}
+
+	// latencyWindow the latest latencies of a route
+	latencyWindow struct {
+		key    string
+		values []time.Duration
+		next   int
+	}
+
+	// cancelOnClose cancels the attempt context when the response body is closed
+	cancelOnClose struct {
+		io.ReadCloser
+		cancel context.CancelFunc
+	}
+)
+
+// NewHedging creates middleware which sends the second attempt after the delay.
+// Only GET, HEAD and OPTIONS requests are hedged, extra attempts are limited by DefaultHedgingBudget.
+func NewHedging(delay time.Duration) *Hedging {
+	return &Hedging{
+		delay:       delay,
+		maxAttempts: DefaultHedgingMaxAttempts,
+		budget:      DefaultHedgingBudget,
+		keyFn:       routeKey,
+		methods: map[string]bool{
+			http.MethodGet:     true,
+			http.MethodHead:    true,
+			http.MethodOptions: true,
+		},
+		maxRoutes: DefaultHedgingMaxRoutes,
+		routes:    list.New(),
+		latencies: make(map[string]*list.Element),
+	}
+}
+
+// WithPercentileDelay the delay is the observed latency percentile (e.g. 0.95) of the route
+// for the latest window requests. The fixed delay is used until enough latencies are observed.
+func (h *Hedging) WithPercentileDelay(percentile float64, window int) *Hedging {
+	h.percentile = percentile
+	h.window = window
+	return h
+}
+
+// WithMaxAttempts total number of attempts including the original request
+func (h *Hedging) WithMaxAttempts(attempts int) *Hedging {
+	h.maxAttempts = attempts
+	return h
+}
+
+// WithBudget ratio of extra attempts to requests, e.g. 0.1 allows to hedge 10% of requests
+func (h *Hedging) WithBudget(ratio float64) *Hedging {
+	h.budget = ratio
+	return h
+}
+
+// WithKeyFunc groups latencies for the percentile delay, method with host and path by default.
+// Use a key which normalises IDs in the path (e.g. NewURLFormatFunc), every key has its own window.
+func (h *Hedging) WithKeyFunc(fn profile.KeyFunc) *Hedging {
+	h.keyFn = fn
+	return h
+}
+
+// WithMaxRoutes number of keys with latencies, DefaultHedgingMaxRoutes by default.
+// The least recently used key is evicted.
+func (h *Hedging) WithMaxRoutes(maxRoutes int) *Hedging {
+	h.maxRoutes = maxRoutes
+	return h
+}
+
+// WithMethods idempotent methods which can be hedged
+func (h *Hedging) WithMethods(methods ...string) *Hedging {
+	h.methods = make(map[string]bool, len(methods))
+	for _, method := range methods {
+		h.methods[method] = true
+	}
+	return h
+}
+
+// HedgeAttemptFromContext number of the attempt (starting from 1), 0 for requests which have not been hedged.
+// The attempt which has served the response is available from response.Request.Context(),
+// the losing attempts are canceled as abandoned (see net.IsAbandoned)
+func HedgeAttemptFromContext(ctx context.Context) int {
+	attempt, _ := ctx.Value(hedgeAttemptKey).(int)
+	return attempt
+}
+
+func (h *Hedging) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		if !h.methods[request.Method] || h.maxAttempts < 2 {
+			return next.RoundTrip(request)
+		}
+
+		body, err := readRequestBody(request)
+		if err != nil {
+			return nil, err
+		}
+
+		key := h.keyFn(request)
+		h.deposit()
+
+		results := make(chan hedgeResult, h.maxAttempts)
+		abandons := make([]context.CancelFunc, 0, h.maxAttempts)
+		send := func(attempt int) {
+			ctx, cancel := context.WithCancel(context.WithValue(request.Context(), hedgeAttemptKey, attempt))
+			ctx, abandon := net.ContextWithAbandon(ctx)
+			abandons = append(abandons, func() {
+				abandon()
+				cancel()
+			})
+			r := CloneRequest(request).WithContext(ctx)
+			recoverRequestBody(r, body)
+
+			go func() {
+				start := time.Now()
+				response, err := next.RoundTrip(r)
+				results <- hedgeResult{response: response, err: err, cancel: cancel, latency: time.Since(start), attempt: attempt}
+			}()
+		}
+
+		send(1)
+		attempts, pending := 1, 1
+		timer := time.NewTimer(h.currentDelay(key))
+		defer timer.Stop()
+
+		var failed *hedgeResult
+		for pending > 0 {
+			select {
+			case result := <-results:
+				pending--
+				if result.err == nil && result.response.StatusCode < http.StatusInternalServerError {
+					h.observe(key, result.latency)
+					// Losing attempts are abandoned, middlewares below do not treat them as failures
+					for attempt, abandon := range abandons {
+						if attempt+1 != result.attempt {
+							abandon()
+						}
+					}
+					h.discard(results, pending, failed)
+					result.response.Body = &cancelOnClose{ReadCloser: result.response.Body, cancel: result.cancel}
+					return result.response, nil
+				}
+				// Response is more informative than transport error
+				if failed == nil || (failed.response == nil && result.response != nil) {
+					if failed != nil {
+						failed.close()
+					}
+					failed = &result
+				} else {
+					result.close()
+				}
+			case <-timer.C:
+				if attempts < h.maxAttempts && h.withdraw() {
+					attempts++
+					pending++
+					send(attempts)
+					timer.Reset(h.currentDelay(key))
+				}
+			}
+		}
+
+		// All attempts have failed, the first failed response (or the first error) is returned
+		if failed.response != nil {
+			failed.response.Body = &cancelOnClose{ReadCloser: failed.response.Body, cancel: failed.cancel}
+		} else {
+			failed.cancel()
+		}
+		return failed.response, failed.err
+	})
+}
+
+// discard cancels pending attempts and releases the previously failed one
+func (h *Hedging) discard(results chan hedgeResult, pending int, failed *hedgeResult) {
+	if failed != nil {
+		failed.close()
+	}
+	if pending == 0 {
+		return
+	}
+
+	go func() {
+		for i := 0; i < pending; i++ {
+			result := <-results
+			result.close()
+		}
+	}()
+}
+
+func (h *Hedging) currentDelay(key string) time.Duration {
+	if h.percentile <= 0 {
+		return h.delay
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if element, ok := h.latencies[key]; ok {
+		if latencies := element.Value.(*latencyWindow); len(latencies.values) >= hedgingMinSamples {
+			return latencies.percentile(h.percentile)
+		}
+	}
+	return h.delay
+}
+
+func (h *Hedging) observe(key string, latency time.Duration) {
+	if h.percentile <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if element, ok := h.latencies[key]; ok {
+		h.routes.MoveToFront(element)
+		element.Value.(*latencyWindow).add(latency)
+		return
+	}
+
+	window := h.window
+	if window <= 0 {
+		window = DefaultHedgingWindow
+	}
+	latencies := &latencyWindow{key: key, values: make([]time.Duration, 0, window)}
+	latencies.add(latency)
+	h.latencies[key] = h.routes.PushFront(latencies)
+
+	if h.maxRoutes > 0 && h.routes.Len() > h.maxRoutes {
+		oldest := h.routes.Remove(h.routes.Back()).(*latencyWindow)
+		delete(h.latencies, oldest.key)
+	}
+}
+
+// deposit adds budget for every request
+func (h *Hedging) deposit() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.tokens += h.budget
+	if h.tokens > hedgingMaxTokens {
+		h.tokens = hedgingMaxTokens
+	}
+}
+
+// withdraw takes budget for the extra attempt
+func (h *Hedging) withdraw() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tokens < 1 {
+		return false
+	}
+	h.tokens--
+	return true
+}
+
+func (r hedgeResult) close() {
+	if r.response != nil {
+		r.response.Body.Close()
+	}
+	r.cancel()
+}
+
+func (w *latencyWindow) add(latency time.Duration) {
+	if len(w.values) < cap(w.values) {
+		w.values = append(w.values, latency)
+		return
+	}
+	w.values[w.next] = latency
+	w.next = (w.next + 1) % len(w.values)
+}
+
+// percentile latency percentile (e.g. 0.95), same method as profile.Collector
+func (w *latencyWindow) percentile(p float64) time.Duration {
+	sorted := make([]float64, len(w.values))
+	for i, latency := range w.values {
+		sorted[i] = float64(latency)
+	}
+	sort.Float64s(sorted)
+
+	return time.Duration(profile.Percentile(sorted, p))
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// routeKey method, host and path of the request
+func routeKey(r *http.Request) string {
+	return r.Method + " " + r.URL.Host + r.URL.Path
+}
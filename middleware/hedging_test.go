@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/best-expendables/httpclient/net"
+	"github.com/best-expendables/httpclient/net/profile"
+	log "github.com/best-expendables/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedging(t *testing.T) {
+	a := assert.New(t)
+
+	var calls, canceled int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		// The first attempt is served by the slow replica
+		if atomic.AddInt32(&calls, 1)%2 == 1 {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+				atomic.AddInt32(&canceled, 1)
+				return
+			}
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	hedging := NewHedging(20*time.Millisecond).WithBudget(1).WithMethods(http.MethodGet, http.MethodPost)
+	client := &http.Client{Transport: WithMiddleware(nil, hedging)}
+
+	start := time.Now()
+	response, err := client.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+	a.NoError(err)
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+
+	a.True(time.Since(start) < 500*time.Millisecond)
+	a.Equal("payload", string(body))
+	a.Equal(2, HedgeAttemptFromContext(response.Request.Context()))
+	a.Equal(int32(2), atomic.LoadInt32(&calls))
+
+	time.Sleep(20 * time.Millisecond)
+	a.Equal(int32(1), atomic.LoadInt32(&canceled))
+}
+
+func TestHedging_Budget(t *testing.T) {
+	a := assert.New(t)
+
+	var calls int32
+	next := RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-time.After(20 * time.Millisecond):
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+	})
+	rt := NewHedging(time.Millisecond).WithBudget(0.5).RoundTripper(next)
+
+	for i := 0; i < 4; i++ {
+		request, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		response, err := rt.RoundTrip(request)
+		a.NoError(err)
+		response.Body.Close()
+	}
+
+	// Every second request is hedged
+	a.Equal(int32(6), atomic.LoadInt32(&calls))
+
+	request, _ := http.NewRequest(http.MethodPut, "http://example.com", nil)
+	response, err := NewHedging(time.Millisecond).WithBudget(1).RoundTripper(next).RoundTrip(request)
+	a.NoError(err)
+	a.Equal(0, HedgeAttemptFromContext(response.Request.Context()))
+	a.Equal(int32(7), atomic.LoadInt32(&calls))
+}
+
+func TestHedging_AllFailed(t *testing.T) {
+	a := assert.New(t)
+
+	var calls int32
+	next := RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(10 * time.Millisecond)
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody, Request: r}, nil
+		}
+		return nil, errors.New("connection refused")
+	})
+	rt := NewHedging(time.Millisecond).WithBudget(2).WithMaxAttempts(3).RoundTripper(next)
+
+	request, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	response, err := rt.RoundTrip(request)
+
+	a.NoError(err)
+	a.Equal(http.StatusBadGateway, response.StatusCode)
+	a.Equal(int32(3), atomic.LoadInt32(&calls))
+	response.Body.Close()
+}
+
+func TestHedging_PercentileDelay(t *testing.T) {
+	a := assert.New(t)
+
+	hedging := NewHedging(time.Hour).WithPercentileDelay(0.95, 20)
+	request, _ := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+	key := routeKey(request)
+	a.Equal("GET example.com/users", key)
+
+	a.Equal(time.Hour, hedging.currentDelay(key))
+	for i := 1; i <= 40; i++ {
+		hedging.observe(key, time.Duration(i)*time.Millisecond)
+	}
+
+	// Window keeps the latest 20 latencies: 21ms..40ms
+	a.Equal(39*time.Millisecond, hedging.currentDelay(key))
+	a.Equal(time.Hour, hedging.currentDelay("GET example.com/orders"))
+
+	// Nearest rank of profile.Collector: ceil(0.91 * 10) = 10th latency
+	hedging = NewHedging(time.Hour).WithPercentileDelay(0.91, 10)
+	sorted := make([]float64, 0, 10)
+	for i := 1; i <= 10; i++ {
+		hedging.observe(key, time.Duration(i)*time.Millisecond)
+		sorted = append(sorted, float64(time.Duration(i)*time.Millisecond))
+	}
+	a.Equal(10*time.Millisecond, hedging.currentDelay(key))
+	a.Equal(time.Duration(profile.Percentile(sorted, 0.91)), hedging.currentDelay(key))
+}
+
+func TestHedging_AbandonedAttempt(t *testing.T) {
+	a := assert.New(t)
+
+	abandoned := make(chan bool, 1)
+	next := RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+		if HedgeAttemptFromContext(r.Context()) == 2 {
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+		}
+		<-r.Context().Done()
+		abandoned <- net.IsAbandoned(r.Context())
+		return nil, r.Context().Err()
+	})
+
+	buffer := bytes.NewBufferString("")
+	logger := log.NewLoggerFactory(log.InfoLevel, log.SetOut(buffer)).Logger(context.TODO())
+	hedging := NewHedging(10 * time.Millisecond).WithBudget(1)
+	transport := hedging.RoundTripper(NewResponseLogger(logger).RoundTripper(next))
+
+	request, _ := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+	response, err := transport.RoundTrip(request)
+	a.NoError(err)
+	response.Body.Close()
+
+	a.True(<-abandoned, "losing attempt is canceled as abandoned")
+	a.NotContains(buffer.String(), "Request has failed")
+	a.False(net.IsAbandoned(response.Request.Context()))
+}
+
+func TestHedging_MaxRoutes(t *testing.T) {
+	a := assert.New(t)
+
+	hedging := NewHedging(time.Hour).WithPercentileDelay(0.95, 10).WithMaxRoutes(2)
+	hedging.observe("GET example.com/users/1", time.Millisecond)
+	hedging.observe("GET example.com/users/2", time.Millisecond)
+	hedging.observe("GET example.com/users/1", time.Millisecond)
+	hedging.observe("GET example.com/users/3", time.Millisecond)
+
+	a.Len(hedging.latencies, 2)
+	a.Equal(2, hedging.routes.Len())
+	a.Contains(hedging.latencies, "GET example.com/users/1", "recently used route is kept")
+	a.NotContains(hedging.latencies, "GET example.com/users/2")
+}
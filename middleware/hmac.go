@@ -0,0 +1,300 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors of the HMACVerifier
+var (
+	ErrHMACMissingSignature = errors.New("hmac: missing signature headers")
+	ErrHMACUnknownKey       = errors.New("hmac: unknown key id")
+	ErrHMACInvalidSignature = errors.New("hmac: invalid signature")
+	ErrHMACTimestampSkew    = errors.New("hmac: timestamp is out of the allowed skew")
+	ErrHMACReplayedNonce    = errors.New("hmac: nonce has been already used")
+)
+
+type (
+	// HMACSpec canonicalisation spec, empty header names are replaced with defaults
+	//
+	// Canonical string (lines are joined with "\n"):
+	//	METHOD
+	//	/escaped/path
+	//	sorted=query&string=
+	//	signed-header:trimmed value (one line per header from Headers)
+	//	hex(sha256(body))
+	//	timestamp (unix seconds)
+	//	nonce
+	HMACSpec struct {
+		// Headers signed headers, missing headers are signed as empty values
+		Headers []string
+
+		KeyIDHeader     string
+		TimestampHeader string
+		NonceHeader     string
+		SignatureHeader string
+	}
+
+	// HMACKeyring keys by key ID. Active key is used for signing, all keys are accepted by the verifier.
+	HMACKeyring struct {
+		Active string
+		Keys   map[string][]byte
+	}
+
+	// HMACSigner signs requests with HMAC-SHA256
+	HMACSigner struct {
+		keys HMACKeyring
+		spec HMACSpec
+		now  func() time.Time
+	}
+
+	// HMACVerifier server-side verification of HMACSigner signatures
+	HMACVerifier struct {
+		keys    HMACKeyring
+		spec    HMACSpec
+		maxSkew time.Duration
+		now     func() time.Time
+
+		mu     sync.Mutex
+		nonces map[string]time.Time
+		// expiries used nonces from the oldest, the expiration times grow with the verification time
+		expiries *list.List
+	}
+
+	usedNonce struct {
+		nonce  string
+		expiry time.Time
+	}
+)
+
+// DefaultHMACSpec signs Content-Type header, uses X-Key-Id, X-Timestamp, X-Nonce and X-Signature headers
+func DefaultHMACSpec() HMACSpec {
+	return HMACSpec{
+		Headers:         []string{"Content-Type"},
+		KeyIDHeader:     "X-Key-Id",
+		TimestampHeader: "X-Timestamp",
+		NonceHeader:     "X-Nonce",
+		SignatureHeader: "X-Signature",
+	}
+}
+
+func (s HMACSpec) withDefaults() HMACSpec {
+	defaults := DefaultHMACSpec()
+	if s.KeyIDHeader == "" {
+		s.KeyIDHeader = defaults.KeyIDHeader
+	}
+	if s.TimestampHeader == "" {
+		s.TimestampHeader = defaults.TimestampHeader
+	}
+	if s.NonceHeader == "" {
+		s.NonceHeader = defaults.NonceHeader
+	}
+	if s.SignatureHeader == "" {
+		s.SignatureHeader = defaults.SignatureHeader
+	}
+	return s
+}
+
+// CanonicalString builds the string to sign
+func (s HMACSpec) CanonicalString(request *http.Request, body []byte, timestamp, nonce string) string {
+	bodyHash := sha256.Sum256(body)
+
+	lines := []string{
+		strings.ToUpper(request.Method),
+		request.URL.EscapedPath(),
+		canonicalQuery(request.URL.Query()),
+	}
+	for _, name := range s.Headers {
+		lines = append(lines, strings.ToLower(name)+":"+strings.TrimSpace(request.Header.Get(name)))
+	}
+	lines = append(lines, hex.EncodeToString(bodyHash[:]), timestamp, nonce)
+
+	return strings.Join(lines, "\n")
+}
+
+// NewHMACSigner creates a signing middleware
+func NewHMACSigner(keys HMACKeyring, spec HMACSpec) *HMACSigner {
+	return &HMACSigner{
+		keys: keys,
+		spec: spec.withDefaults(),
+		now:  time.Now,
+	}
+}
+
+func (s *HMACSigner) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		key, ok := s.keys.Keys[s.keys.Active]
+		if !ok {
+			return nil, ErrHMACUnknownKey
+		}
+
+		body, err := readRequestBody(request)
+		if err != nil {
+			return nil, err
+		}
+
+		nonce, err := newNonce()
+		if err != nil {
+			return nil, err
+		}
+		timestamp := strconv.FormatInt(s.now().Unix(), 10)
+
+		request = CloneRequest(request)
+		recoverRequestBody(request, body)
+		request.Header.Set(s.spec.KeyIDHeader, s.keys.Active)
+		request.Header.Set(s.spec.TimestampHeader, timestamp)
+		request.Header.Set(s.spec.NonceHeader, nonce)
+		request.Header.Set(s.spec.SignatureHeader, hmacSign(key, s.spec.CanonicalString(request, body, timestamp, nonce)))
+
+		return next.RoundTrip(request)
+	})
+}
+
+// NewHMACVerifier creates a verifier, requests with a timestamp older than maxSkew or replayed nonce are rejected
+func NewHMACVerifier(keys HMACKeyring, spec HMACSpec, maxSkew time.Duration) *HMACVerifier {
+	return &HMACVerifier{
+		keys:     keys,
+		spec:     spec.withDefaults(),
+		maxSkew:  maxSkew,
+		now:      time.Now,
+		nonces:   make(map[string]time.Time),
+		expiries: list.New(),
+	}
+}
+
+// Verify checks the request signature. Request body is restored after reading.
+func (v *HMACVerifier) Verify(request *http.Request) error {
+	keyID := request.Header.Get(v.spec.KeyIDHeader)
+	timestamp := request.Header.Get(v.spec.TimestampHeader)
+	nonce := request.Header.Get(v.spec.NonceHeader)
+	signature := request.Header.Get(v.spec.SignatureHeader)
+	if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+		return ErrHMACMissingSignature
+	}
+
+	key, ok := v.keys.Keys[keyID]
+	if !ok {
+		return ErrHMACUnknownKey
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrHMACTimestampSkew
+	}
+	now := v.now()
+	if skew := now.Sub(time.Unix(unix, 0)); skew > v.maxSkew || skew < -v.maxSkew {
+		return ErrHMACTimestampSkew
+	}
+
+	body, err := readRequestBody(request)
+	if err != nil {
+		return err
+	}
+	recoverRequestBody(request, body)
+
+	expected := hmacSign(key, v.spec.CanonicalString(request, body, timestamp, nonce))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrHMACInvalidSignature
+	}
+
+	return v.useNonce(keyID+":"+nonce, now)
+}
+
+// Handler rejects requests with invalid signature with 401 status
+func (v *HMACVerifier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.Verify(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v *HMACVerifier) useNonce(nonce string, now time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for oldest := v.expiries.Front(); oldest != nil; oldest = v.expiries.Front() {
+		used := oldest.Value.(usedNonce)
+		if !now.After(used.expiry) {
+			break
+		}
+		v.expiries.Remove(oldest)
+		delete(v.nonces, used.nonce)
+	}
+
+	if _, ok := v.nonces[nonce]; ok {
+		return ErrHMACReplayedNonce
+	}
+	// Requests with older timestamp are rejected by the skew check
+	expiry := now.Add(2 * v.maxSkew)
+	v.nonces[nonce] = expiry
+	v.expiries.PushBack(usedNonce{nonce: nonce, expiry: expiry})
+
+	return nil
+}
+
+func hmacSign(key []byte, canonical string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(canonical))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.Buffer{}
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			if buf.Len() > 0 {
+				buf.WriteByte('&')
+			}
+			buf.WriteString(url.QueryEscape(key))
+			buf.WriteByte('=')
+			buf.WriteString(url.QueryEscape(value))
+		}
+	}
+
+	return buf.String()
+}
+
+// readRequestBody reads the body and restores it, nil for request without body
+func readRequestBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	recoverRequestBody(request, body)
+
+	return body, err
+}
+
+func newNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
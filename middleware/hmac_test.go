@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var hmacKeys = HMACKeyring{
+	Active: "2020-02",
+	Keys: map[string][]byte{
+		"2020-01": []byte("old-secret"),
+		"2020-02": []byte("new-secret"),
+	},
+}
+
+func TestHMACSigner(t *testing.T) {
+	a := assert.New(t)
+
+	verifier := NewHMACVerifier(hmacKeys, HMACSpec{Headers: []string{"Content-Type", "X-Tenant"}}, time.Minute)
+
+	var signed *http.Request
+	srv := httptest.NewServer(verifier.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		a.Equal(`{"amount":100}`, string(body), "Body should be restored")
+		signed = r
+	})))
+	defer srv.Close()
+
+	signer := NewHMACSigner(hmacKeys, HMACSpec{Headers: []string{"Content-Type", "X-Tenant"}})
+	client := &http.Client{Transport: WithMiddleware(nil, signer)}
+
+	request, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/payments?b=2&a=1&a=0", bytes.NewBufferString(`{"amount":100}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Tenant", "vn")
+
+	response, err := client.Do(request)
+	a.NoError(err)
+	a.Equal(http.StatusOK, response.StatusCode)
+	a.Equal("2020-02", signed.Header.Get("X-Key-Id"))
+	a.Empty(request.Header.Get("X-Signature"), "Original request has been changed")
+
+	t.Run("Replayed request", func(t *testing.T) {
+		replay, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/payments?b=2&a=1&a=0", bytes.NewBufferString(`{"amount":100}`))
+		replay.Header = signed.Header
+
+		response, err := http.DefaultClient.Do(replay)
+		a.NoError(err)
+		a.Equal(http.StatusUnauthorized, response.StatusCode)
+	})
+
+	t.Run("Tampered body", func(t *testing.T) {
+		tamper := RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+			r.Body = ioutil.NopCloser(bytes.NewBufferString(`{"amount":1000}`))
+			r.ContentLength = 15
+			return http.DefaultTransport.RoundTrip(r)
+		})
+		client := &http.Client{Transport: signer.RoundTripper(tamper)}
+
+		response, err := client.Post(srv.URL+"/v1/payments", "application/json", bytes.NewBufferString(`{"amount":100}`))
+		a.NoError(err)
+		a.Equal(http.StatusUnauthorized, response.StatusCode)
+	})
+}
+
+func TestHMACVerifier_Verify(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Unix(1590000000, 0)
+	signer := NewHMACSigner(HMACKeyring{Active: "2020-01", Keys: hmacKeys.Keys}, DefaultHMACSpec())
+	signer.now = func() time.Time { return now }
+
+	sign := func() *http.Request {
+		var signed *http.Request
+		transport := signer.RoundTripper(RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+			signed = r
+			return nil, nil
+		}))
+		request, _ := http.NewRequest(http.MethodGet, "http://partner.io/v1/orders?id=1", nil)
+		transport.RoundTrip(request)
+		return signed
+	}
+
+	verifier := NewHMACVerifier(hmacKeys, DefaultHMACSpec(), time.Minute)
+
+	verifier.now = func() time.Time { return now.Add(30 * time.Second) }
+	a.NoError(verifier.Verify(sign()), "Rotated key should be accepted")
+
+	verifier.now = func() time.Time { return now.Add(2 * time.Minute) }
+	a.Equal(ErrHMACTimestampSkew, verifier.Verify(sign()))
+
+	verifier.now = func() time.Time { return now }
+	request := sign()
+	request.URL, _ = url.Parse("http://partner.io/v1/orders?id=2")
+	a.Equal(ErrHMACInvalidSignature, verifier.Verify(request))
+
+	request = sign()
+	request.Header.Set("X-Key-Id", "2019-12")
+	a.Equal(ErrHMACUnknownKey, verifier.Verify(request))
+
+	request = sign()
+	request.Header.Del("X-Signature")
+	a.Equal(ErrHMACMissingSignature, verifier.Verify(request))
+
+	request = sign()
+	a.NoError(verifier.Verify(request))
+	a.Equal(ErrHMACReplayedNonce, verifier.Verify(request))
+
+	// Expired nonces are evicted
+	now = now.Add(5 * time.Minute)
+	verifier.now = func() time.Time { return now }
+	a.NoError(verifier.Verify(sign()))
+	a.Len(verifier.nonces, 1)
+	a.Equal(1, verifier.expiries.Len())
+}
+
+func TestHMACSpec_CanonicalString(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodPost, "http://partner.io/v1/a%20b?z=1&a=2&a=1", nil)
+	request.Header.Set("Content-Type", " application/json ")
+
+	expected := "POST\n" +
+		"/v1/a%20b\n" +
+		"a=1&a=2&z=1\n" +
+		"content-type:application/json\n" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855\n" +
+		"1590000000\n" +
+		"nonce"
+
+	assert.Equal(t, expected, DefaultHMACSpec().CanonicalString(request, nil, "1590000000", "nonce"))
+}
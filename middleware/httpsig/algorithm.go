@@ -0,0 +1,165 @@
+package httpsig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"math/big"
+)
+
+// ErrInvalidSignature signature does not match
+var ErrInvalidSignature = errors.New("httpsig: invalid signature")
+
+type (
+	// Algorithm signs and verifies the signature base (RFC 9421, section 3.3)
+	Algorithm interface {
+		// Name registered algorithm name, e.g. "ed25519"
+		Name() string
+		Sign(base []byte) ([]byte, error)
+		Verify(base, signature []byte) error
+	}
+
+	ed25519Algorithm struct {
+		private ed25519.PrivateKey
+		public  ed25519.PublicKey
+	}
+
+	ecdsaAlgorithm struct {
+		private *ecdsa.PrivateKey
+		public  *ecdsa.PublicKey
+	}
+
+	rsaPSSAlgorithm struct {
+		private *rsa.PrivateKey
+		public  *rsa.PublicKey
+	}
+
+	hmacAlgorithm []byte
+)
+
+// Ed25519 "ed25519" algorithm, private key is optional for verification
+func Ed25519(private ed25519.PrivateKey, public ed25519.PublicKey) Algorithm {
+	if public == nil && private != nil {
+		public = private.Public().(ed25519.PublicKey)
+	}
+	return ed25519Algorithm{private: private, public: public}
+}
+
+func (ed25519Algorithm) Name() string {
+	return "ed25519"
+}
+
+func (a ed25519Algorithm) Sign(base []byte) ([]byte, error) {
+	if a.private == nil {
+		return nil, errors.New("httpsig: ed25519 private key is missing")
+	}
+	return ed25519.Sign(a.private, base), nil
+}
+
+func (a ed25519Algorithm) Verify(base, signature []byte) error {
+	if !ed25519.Verify(a.public, base, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ECDSAP256 "ecdsa-p256-sha256" algorithm, private key is optional for verification
+func ECDSAP256(private *ecdsa.PrivateKey, public *ecdsa.PublicKey) Algorithm {
+	if public == nil && private != nil {
+		public = &private.PublicKey
+	}
+	return ecdsaAlgorithm{private: private, public: public}
+}
+
+func (ecdsaAlgorithm) Name() string {
+	return "ecdsa-p256-sha256"
+}
+
+// Sign signature is r and s concatenated as 32-byte big-endian integers
+func (a ecdsaAlgorithm) Sign(base []byte) ([]byte, error) {
+	if a.private == nil {
+		return nil, errors.New("httpsig: ecdsa private key is missing")
+	}
+	hash := sha256.Sum256(base)
+	r, s, err := ecdsa.Sign(rand.Reader, a.private, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signature, nil
+}
+
+func (a ecdsaAlgorithm) Verify(base, signature []byte) error {
+	if len(signature) != 64 || a.public.Curve != elliptic.P256() {
+		return ErrInvalidSignature
+	}
+	hash := sha256.Sum256(base)
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(a.public, hash[:], r, s) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// RSAPSS "rsa-pss-sha512" algorithm, private key is optional for verification
+func RSAPSS(private *rsa.PrivateKey, public *rsa.PublicKey) Algorithm {
+	if public == nil && private != nil {
+		public = &private.PublicKey
+	}
+	return rsaPSSAlgorithm{private: private, public: public}
+}
+
+func (rsaPSSAlgorithm) Name() string {
+	return "rsa-pss-sha512"
+}
+
+func (a rsaPSSAlgorithm) Sign(base []byte) ([]byte, error) {
+	if a.private == nil {
+		return nil, errors.New("httpsig: rsa private key is missing")
+	}
+	hash := sha512.Sum512(base)
+	return rsa.SignPSS(rand.Reader, a.private, crypto.SHA512, hash[:], &rsa.PSSOptions{SaltLength: 64})
+}
+
+func (a rsaPSSAlgorithm) Verify(base, signature []byte) error {
+	hash := sha512.Sum512(base)
+	if err := rsa.VerifyPSS(a.public, crypto.SHA512, hash[:], signature, &rsa.PSSOptions{SaltLength: 64}); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// HMACSHA256 "hmac-sha256" algorithm with the shared secret
+func HMACSHA256(key []byte) Algorithm {
+	return hmacAlgorithm(key)
+}
+
+func (hmacAlgorithm) Name() string {
+	return "hmac-sha256"
+}
+
+func (a hmacAlgorithm) Sign(base []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, a)
+	mac.Write(base)
+	return mac.Sum(nil), nil
+}
+
+func (a hmacAlgorithm) Verify(base, signature []byte) error {
+	expected, _ := a.Sign(base)
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
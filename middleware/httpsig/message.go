@@ -0,0 +1,187 @@
+package httpsig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Content-Digest algorithms (RFC 9530)
+const (
+	DigestSHA256 = "sha-256"
+	DigestSHA512 = "sha-512"
+)
+
+// message HTTP request or response as a source of the covered components
+type message struct {
+	method    string
+	scheme    string
+	authority string
+	url       *url.URL
+	header    http.Header
+	status    int
+}
+
+func requestMessage(r *http.Request) message {
+	scheme := r.URL.Scheme
+	if scheme == "" {
+		// Server side request
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	return message{
+		method:    r.Method,
+		scheme:    strings.ToLower(scheme),
+		authority: authority(scheme, host),
+		url:       r.URL,
+		header:    r.Header,
+	}
+}
+
+func responseMessage(r *http.Response) message {
+	return message{
+		header: r.Header,
+		status: r.StatusCode,
+	}
+}
+
+// authority lower-cased host, default port is omitted
+func authority(scheme, host string) string {
+	host = strings.ToLower(host)
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+			return h
+		}
+	}
+	return host
+}
+
+// componentValue RFC 9421, section 2
+func (m message) componentValue(name string) (string, error) {
+	if !strings.HasPrefix(name, "@") {
+		values, ok := m.header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return "", fmt.Errorf("httpsig: covered header %q is missing", name)
+		}
+		trimmed := make([]string, 0, len(values))
+		for _, value := range values {
+			trimmed = append(trimmed, strings.TrimSpace(value))
+		}
+		return strings.Join(trimmed, ", "), nil
+	}
+
+	if name == "@status" {
+		if m.status == 0 {
+			return "", fmt.Errorf("httpsig: %s is available only for responses", name)
+		}
+		return strconv.Itoa(m.status), nil
+	}
+
+	if m.url == nil {
+		return "", fmt.Errorf("httpsig: %s is available only for requests", name)
+	}
+
+	switch name {
+	case "@method":
+		return strings.ToUpper(m.method), nil
+	case "@scheme":
+		return m.scheme, nil
+	case "@authority":
+		return m.authority, nil
+	case "@target-uri":
+		return m.scheme + "://" + m.authority + m.url.RequestURI(), nil
+	case "@request-target":
+		return m.url.RequestURI(), nil
+	case "@path":
+		if path := m.url.EscapedPath(); path != "" {
+			return path, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + m.url.RawQuery, nil
+	}
+
+	return "", fmt.Errorf("httpsig: unsupported component %q", name)
+}
+
+// signatureBase RFC 9421, section 2.5
+func signatureBase(m message, components []string, params string) (string, error) {
+	buf := bytes.Buffer{}
+	for _, component := range components {
+		value, err := m.componentValue(component)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&buf, "%q: %s\n", component, value)
+	}
+	fmt.Fprintf(&buf, "%q: %s", "@signature-params", params)
+
+	return buf.String(), nil
+}
+
+// ContentDigest returns Content-Digest header value, e.g. "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"
+func ContentDigest(algorithm string, body []byte) (string, error) {
+	var digest []byte
+	switch algorithm {
+	case DigestSHA256:
+		hash := sha256.Sum256(body)
+		digest = hash[:]
+	case DigestSHA512:
+		hash := sha512.Sum512(body)
+		digest = hash[:]
+	default:
+		return "", fmt.Errorf("httpsig: unsupported digest algorithm %q", algorithm)
+	}
+
+	return algorithm + "=:" + base64.StdEncoding.EncodeToString(digest) + ":", nil
+}
+
+// verifyContentDigest checks every supported digest of the header
+func verifyContentDigest(header string, body []byte) error {
+	verified := false
+	for _, member := range splitMembers(header) {
+		algorithm := strings.TrimSpace(strings.SplitN(member, "=", 2)[0])
+		if algorithm != DigestSHA256 && algorithm != DigestSHA512 {
+			continue
+		}
+		expected, _ := ContentDigest(algorithm, body)
+		if strings.TrimSpace(member) != expected {
+			return fmt.Errorf("httpsig: content digest %s does not match", algorithm)
+		}
+		verified = true
+	}
+
+	if !verified {
+		return fmt.Errorf("httpsig: content digest with supported algorithm is missing")
+	}
+	return nil
+}
+
+// readBody reads and restores the body
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	content, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	*body = ioutil.NopCloser(bytes.NewReader(content))
+
+	return content, err
+}
@@ -0,0 +1,130 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/best-expendables/httpclient/middleware"
+)
+
+// DefaultLabel label of the signature in Signature-Input and Signature headers
+const DefaultLabel = "sig1"
+
+// DefaultComponents covered components if none are given
+var DefaultComponents = []string{"@method", "@target-uri", "@authority"}
+
+// Signer signs outgoing requests according to RFC 9421
+type Signer struct {
+	label      string
+	keyID      string
+	algorithm  Algorithm
+	components []string
+	digest     string
+	algParam   bool
+	now        func() time.Time
+}
+
+// NewSigner creates signing middleware, DefaultComponents are covered if components are empty
+func NewSigner(keyID string, algorithm Algorithm, components ...string) *Signer {
+	if len(components) == 0 {
+		components = DefaultComponents
+	}
+
+	return &Signer{
+		label:      DefaultLabel,
+		keyID:      keyID,
+		algorithm:  algorithm,
+		components: components,
+		now:        time.Now,
+	}
+}
+
+// WithLabel sets the signature label
+func (s *Signer) WithLabel(label string) *Signer {
+	s.label = label
+	return s
+}
+
+// WithContentDigest generates Content-Digest header (DigestSHA256 or DigestSHA512) and covers it by the signature
+func (s *Signer) WithContentDigest(algorithm string) *Signer {
+	s.digest = algorithm
+	return s
+}
+
+// WithAlgorithmParameter adds "alg" parameter, by default the algorithm is derived from the key ID
+func (s *Signer) WithAlgorithmParameter(flag bool) *Signer {
+	s.algParam = flag
+	return s
+}
+
+// RoundTripper middleware function
+func (s *Signer) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFn(func(request *http.Request) (*http.Response, error) {
+		signed, err := s.Sign(request)
+		if err != nil {
+			return nil, err
+		}
+
+		return next.RoundTrip(signed)
+	})
+}
+
+// Sign returns a signed copy of the request
+func (s *Signer) Sign(request *http.Request) (*http.Request, error) {
+	request = middleware.CloneRequest(request)
+	components := s.components
+
+	if s.digest != "" {
+		body, err := readBody(&request.Body)
+		if err != nil {
+			return nil, err
+		}
+		digest, err := ContentDigest(s.digest, body)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Digest", digest)
+
+		if !covers(components, "content-digest") {
+			components = append(append([]string(nil), components...), "content-digest")
+		}
+	}
+
+	alg := ""
+	if s.algParam {
+		alg = s.algorithm.Name()
+	}
+	params := serializeParams(components, s.now().Unix(), s.keyID, alg)
+
+	base, err := signatureBase(requestMessage(request), components, params)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := s.algorithm.Sign([]byte(base))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Add("Signature-Input", s.label+"="+params)
+	request.Header.Add("Signature", s.label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+
+	return request, nil
+}
+
+func covers(components []string, name string) bool {
+	for _, component := range components {
+		if component == name {
+			return true
+		}
+	}
+	return false
+}
+
+type roundTripperFn func(r *http.Request) (*http.Response, error)
+
+// RoundTrip
+func (f roundTripperFn) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
@@ -0,0 +1,114 @@
+package httpsig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// signatureInput parsed member of the Signature-Input dictionary
+type signatureInput struct {
+	components []string
+	// params serialized inner list with parameters, used as is in the signature base
+	params  string
+	created int64
+	expires int64
+	keyID   string
+	alg     string
+}
+
+// serializeParams inner list of the covered components with the signature parameters
+func serializeParams(components []string, created int64, keyID, alg string) string {
+	quoted := make([]string, 0, len(components))
+	for _, component := range components {
+		quoted = append(quoted, strconv.Quote(component))
+	}
+
+	params := "(" + strings.Join(quoted, " ") + ");created=" + strconv.FormatInt(created, 10)
+	if keyID != "" {
+		params += ";keyid=" + strconv.Quote(keyID)
+	}
+	if alg != "" {
+		params += ";alg=" + strconv.Quote(alg)
+	}
+
+	return params
+}
+
+// parseDictionary minimal parser of structured field dictionary (RFC 8941) keyed by label
+func parseDictionary(header string) map[string]string {
+	dictionary := make(map[string]string)
+	for _, member := range splitMembers(header) {
+		parts := strings.SplitN(strings.TrimSpace(member), "=", 2)
+		if len(parts) == 2 {
+			dictionary[parts[0]] = parts[1]
+		}
+	}
+	return dictionary
+}
+
+func parseSignatureInput(value string) (*signatureInput, error) {
+	if !strings.HasPrefix(value, "(") || !strings.Contains(value, ")") {
+		return nil, fmt.Errorf("httpsig: invalid signature input %q", value)
+	}
+
+	input := &signatureInput{params: value}
+
+	end := strings.Index(value, ")")
+	for _, item := range strings.Fields(value[1:end]) {
+		component, err := strconv.Unquote(item)
+		if err != nil || strings.Contains(item, ";") {
+			return nil, fmt.Errorf("httpsig: unsupported component %s", item)
+		}
+		input.components = append(input.components, component)
+	}
+
+	for _, param := range strings.Split(value[end+1:], ";") {
+		parts := strings.SplitN(param, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "created":
+			input.created, _ = strconv.ParseInt(parts[1], 10, 64)
+		case "expires":
+			input.expires, _ = strconv.ParseInt(parts[1], 10, 64)
+		case "keyid":
+			input.keyID, _ = strconv.Unquote(parts[1])
+		case "alg":
+			input.alg, _ = strconv.Unquote(parts[1])
+		}
+	}
+
+	return input, nil
+}
+
+// splitMembers splits a structured field list or dictionary by commas outside of quotes and inner lists
+func splitMembers(header string) []string {
+	var (
+		members []string
+		quoted  bool
+		depth   int
+		start   int
+	)
+	for i := 0; i < len(header); i++ {
+		switch c := header[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == '(' && !quoted:
+			depth++
+		case c == ')' && !quoted:
+			depth--
+		case c == ',' && !quoted && depth == 0:
+			members = append(members, header[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(header[start:]) != "" {
+		members = append(members, header[start:])
+	}
+
+	return members
+}
@@ -0,0 +1,148 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type (
+	// KeyResolver returns the verification algorithm with the key by key ID
+	KeyResolver func(keyID string) (Algorithm, error)
+
+	// Verifier verifies RFC 9421 signatures of responses (as middleware) and requests (server side)
+	Verifier struct {
+		resolve  KeyResolver
+		label    string
+		required []string
+		maxAge   time.Duration
+		now      func() time.Time
+	}
+)
+
+// NewVerifier creates a verifier, the first signature is verified unless WithLabel is set
+func NewVerifier(resolve KeyResolver) *Verifier {
+	return &Verifier{
+		resolve: resolve,
+		now:     time.Now,
+	}
+}
+
+// WithLabel verifies the signature with the label
+func (v *Verifier) WithLabel(label string) *Verifier {
+	v.label = label
+	return v
+}
+
+// WithRequiredComponents rejects signatures which do not cover the components
+func (v *Verifier) WithRequiredComponents(components ...string) *Verifier {
+	v.required = components
+	return v
+}
+
+// WithMaxAge rejects signatures created earlier than maxAge ago
+func (v *Verifier) WithMaxAge(maxAge time.Duration) *Verifier {
+	v.maxAge = maxAge
+	return v
+}
+
+// RoundTripper middleware function verifies response signatures, invalid responses are closed and an error is returned
+func (v *Verifier) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFn(func(request *http.Request) (*http.Response, error) {
+		response, err := next.RoundTrip(request)
+		if err != nil {
+			return response, err
+		}
+
+		if err := v.VerifyResponse(response); err != nil {
+			response.Body.Close()
+			return nil, err
+		}
+
+		return response, nil
+	})
+}
+
+// VerifyRequest verifies a signature of the request, the body is restored after the digest check
+func (v *Verifier) VerifyRequest(request *http.Request) error {
+	return v.verify(requestMessage(request), func() ([]byte, error) {
+		return readBody(&request.Body)
+	})
+}
+
+// VerifyResponse verifies a signature of the response, the body is restored after the digest check
+func (v *Verifier) VerifyResponse(response *http.Response) error {
+	return v.verify(responseMessage(response), func() ([]byte, error) {
+		return readBody(&response.Body)
+	})
+}
+
+func (v *Verifier) verify(m message, body func() ([]byte, error)) error {
+	inputs := strings.Join(m.header["Signature-Input"], ",")
+	signatures := parseDictionary(strings.Join(m.header["Signature"], ","))
+	if len(splitMembers(inputs)) == 0 || len(signatures) == 0 {
+		return fmt.Errorf("httpsig: signature is missing")
+	}
+
+	label := v.label
+	if label == "" {
+		label = strings.TrimSpace(strings.SplitN(splitMembers(inputs)[0], "=", 2)[0])
+	}
+
+	inputValue, ok := parseDictionary(inputs)[label]
+	if !ok {
+		return fmt.Errorf("httpsig: signature %q is missing", label)
+	}
+	input, err := parseSignatureInput(inputValue)
+	if err != nil {
+		return err
+	}
+
+	for _, component := range v.required {
+		if !covers(input.components, component) {
+			return fmt.Errorf("httpsig: required component %q is not covered", component)
+		}
+	}
+
+	now := v.now().Unix()
+	if input.expires > 0 && now > input.expires {
+		return fmt.Errorf("httpsig: signature has expired")
+	}
+	if v.maxAge > 0 && now-input.created > int64(v.maxAge/time.Second) {
+		return fmt.Errorf("httpsig: signature is too old")
+	}
+
+	algorithm, err := v.resolve(input.keyID)
+	if err != nil {
+		return err
+	}
+	if input.alg != "" && input.alg != algorithm.Name() {
+		return fmt.Errorf("httpsig: algorithm %q does not match the key", input.alg)
+	}
+
+	encoded := strings.Trim(strings.TrimSpace(signatures[label]), ":")
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	base, err := signatureBase(m, input.components, input.params)
+	if err != nil {
+		return err
+	}
+	if err := algorithm.Verify([]byte(base), signature); err != nil {
+		return err
+	}
+
+	if covers(input.components, "content-digest") {
+		content, err := body()
+		if err != nil {
+			return err
+		}
+		return verifyContentDigest(m.header.Get("Content-Digest"), content)
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/best-expendables/httpclient/net/profile"
+)
+
+type loggingCtxKey int
+
+const debugLoggingKey loggingCtxKey = iota
+
+type (
+	// LogCall the call which is going to be logged
+	LogCall struct {
+		Request *http.Request
+		// Response is nil for transport errors and for RequestLogger.Process, which logs before the call
+		Response *http.Response
+		Err      error
+		// Duration of the call, 0 for RequestLogger.Process and ResponseLogger.Process
+		Duration time.Duration
+	}
+
+	// LogPolicy decides whether the call is logged
+	LogPolicy interface {
+		ShouldLog(call *LogCall) bool
+	}
+
+	// LogPolicyFn function as LogPolicy
+	LogPolicyFn func(call *LogCall) bool
+
+	// Sampler logs the random part of the calls, the rate can be set per route
+	Sampler struct {
+		rate   float64
+		keyFn  profile.KeyFunc
+		routes map[string]float64
+	}
+
+	// RateLimit logs up to the rate of calls per second for every key.
+	// Buckets of the keys which have been idle until the bucket is full again are evicted.
+	RateLimit struct {
+		rate  float64
+		burst float64
+		keyFn profile.KeyFunc
+
+		mu        sync.Mutex
+		buckets   map[string]*logBucket
+		lastSweep time.Time
+	}
+
+	logBucket struct {
+		tokens float64
+		last   time.Time
+	}
+)
+
+func (f LogPolicyFn) ShouldLog(call *LogCall) bool {
+	return f(call)
+}
+
+// ContextWithDebugLogging forces logging of the calls with the context, see DebugLogging
+func ContextWithDebugLogging(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugLoggingKey, true)
+}
+
+// DebugLoggingFromContext whether the debug logging is requested
+func DebugLoggingFromContext(ctx context.Context) bool {
+	debug, _ := ctx.Value(debugLoggingKey).(bool)
+	return debug
+}
+
+// DebugLogging logs calls with the debug flag in the context (see ContextWithDebugLogging)
+// or with the non-empty request header, empty header name checks only the context
+func DebugLogging(header string) LogPolicy {
+	return LogPolicyFn(func(call *LogCall) bool {
+		if DebugLoggingFromContext(call.Request.Context()) {
+			return true
+		}
+		return header != "" && call.Request.Header.Get(header) != ""
+	})
+}
+
+// ErrorsOnly logs transport errors and responses with status code 400 and above
+func ErrorsOnly() LogPolicy {
+	return LogPolicyFn(func(call *LogCall) bool {
+		return call.Err != nil || (call.Response != nil && call.Response.StatusCode >= http.StatusBadRequest)
+	})
+}
+
+// SlowerThan logs calls which have taken longer than the threshold
+func SlowerThan(threshold time.Duration) LogPolicy {
+	return LogPolicyFn(func(call *LogCall) bool {
+		return call.Duration > threshold
+	})
+}
+
+// AnyOf logs the call if any of the policies allows it
+func AnyOf(policies ...LogPolicy) LogPolicy {
+	return LogPolicyFn(func(call *LogCall) bool {
+		for _, policy := range policies {
+			if policy.ShouldLog(call) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// AllOf logs the call if all the policies allow it, the policies are evaluated in order until the first rejection
+func AllOf(policies ...LogPolicy) LogPolicy {
+	return LogPolicyFn(func(call *LogCall) bool {
+		for _, policy := range policies {
+			if !policy.ShouldLog(call) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// NewSampler logs the rate (from 0 to 1) of the calls
+func NewSampler(rate float64) *Sampler {
+	return &Sampler{
+		rate:   rate,
+		keyFn:  routeKey,
+		routes: make(map[string]float64),
+	}
+}
+
+// WithKeyFunc route of the request, method with host and path by default
+func (s *Sampler) WithKeyFunc(fn profile.KeyFunc) *Sampler {
+	s.keyFn = fn
+	return s
+}
+
+// WithRouteRate sampling rate of the route
+func (s *Sampler) WithRouteRate(route string, rate float64) *Sampler {
+	s.routes[route] = rate
+	return s
+}
+
+func (s *Sampler) ShouldLog(call *LogCall) bool {
+	rate, ok := s.routes[s.keyFn(call.Request)]
+	if !ok {
+		rate = s.rate
+	}
+	return rand.Float64() < rate
+}
+
+// NewRateLimit logs up to perSecond calls for every route with bursts up to burst calls
+func NewRateLimit(perSecond float64, burst int) *RateLimit {
+	return &RateLimit{
+		rate:    perSecond,
+		burst:   float64(burst),
+		keyFn:   routeKey,
+		buckets: make(map[string]*logBucket),
+	}
+}
+
+// WithKeyFunc key of the limit, method with host and path by default
+func (l *RateLimit) WithKeyFunc(fn profile.KeyFunc) *RateLimit {
+	l.keyFn = fn
+	return l
+}
+
+func (l *RateLimit) ShouldLog(call *LogCall) bool {
+	key := l.keyFn(call.Request)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		l.evictIdle(now)
+		bucket = &logBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// evictIdle removes the buckets which are full again, such bucket is the same as a new one.
+// The buckets are swept at most once per refill window.
+func (l *RateLimit) evictIdle(now time.Time) {
+	if l.rate <= 0 {
+		return
+	}
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) < refill {
+		return
+	}
+	l.lastSweep = now
+
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// shouldLog nil policy logs every call
+func shouldLog(policy LogPolicy, call *LogCall) bool {
+	return policy == nil || policy.ShouldLog(call)
+}
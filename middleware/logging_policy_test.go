@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/best-expendables/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogPolicies(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+	ok := &LogCall{Request: request, Response: &http.Response{StatusCode: http.StatusOK}, Duration: time.Millisecond}
+	notFound := &LogCall{Request: request, Response: &http.Response{StatusCode: http.StatusNotFound}}
+	failed := &LogCall{Request: request, Err: errors.New("connection refused")}
+	slow := &LogCall{Request: request, Response: &http.Response{StatusCode: http.StatusOK}, Duration: time.Second}
+
+	t.Run("ErrorsOnly", func(t *testing.T) {
+		assert.False(t, ErrorsOnly().ShouldLog(ok))
+		assert.True(t, ErrorsOnly().ShouldLog(notFound))
+		assert.True(t, ErrorsOnly().ShouldLog(failed))
+	})
+
+	t.Run("SlowerThan", func(t *testing.T) {
+		assert.False(t, SlowerThan(500*time.Millisecond).ShouldLog(ok))
+		assert.True(t, SlowerThan(500*time.Millisecond).ShouldLog(slow))
+	})
+
+	t.Run("DebugLogging", func(t *testing.T) {
+		policy := DebugLogging("X-Debug")
+		assert.False(t, policy.ShouldLog(ok))
+
+		debugRequest := request.WithContext(ContextWithDebugLogging(context.TODO()))
+		assert.True(t, policy.ShouldLog(&LogCall{Request: debugRequest}))
+
+		headerRequest, _ := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+		headerRequest.Header.Set("X-Debug", "1")
+		assert.True(t, policy.ShouldLog(&LogCall{Request: headerRequest}))
+		assert.False(t, DebugLogging("").ShouldLog(&LogCall{Request: headerRequest}))
+	})
+
+	t.Run("Composition", func(t *testing.T) {
+		assert.True(t, AnyOf(ErrorsOnly(), SlowerThan(500*time.Millisecond)).ShouldLog(slow))
+		assert.False(t, AnyOf(ErrorsOnly(), SlowerThan(500*time.Millisecond)).ShouldLog(ok))
+		assert.False(t, AllOf(ErrorsOnly(), SlowerThan(500*time.Millisecond)).ShouldLog(notFound))
+		assert.True(t, AllOf().ShouldLog(ok))
+	})
+
+	t.Run("Sampler", func(t *testing.T) {
+		sampler := NewSampler(0).WithRouteRate("GET example.com/users", 1)
+		other, _ := http.NewRequest(http.MethodGet, "http://example.com/orders", nil)
+
+		assert.True(t, sampler.ShouldLog(ok))
+		assert.False(t, sampler.ShouldLog(&LogCall{Request: other}))
+	})
+
+	t.Run("RateLimit", func(t *testing.T) {
+		limit := NewRateLimit(0.001, 2)
+		other, _ := http.NewRequest(http.MethodGet, "http://example.com/orders", nil)
+
+		assert.True(t, limit.ShouldLog(ok))
+		assert.True(t, limit.ShouldLog(ok))
+		assert.False(t, limit.ShouldLog(ok))
+		assert.True(t, limit.ShouldLog(&LogCall{Request: other}), "limit is per route")
+	})
+
+	t.Run("RateLimit eviction", func(t *testing.T) {
+		limit := NewRateLimit(1000, 1)
+		for i := 0; i < 10; i++ {
+			request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://example.com/users/%d", i), nil)
+			assert.True(t, limit.ShouldLog(&LogCall{Request: request}))
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		assert.True(t, limit.ShouldLog(ok))
+		assert.Len(t, limit.buckets, 1, "idle buckets are evicted")
+	})
+}
+
+func TestLogPolicy_Loggers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte("response"))
+	}))
+	defer server.Close()
+
+	buffer := bytes.NewBufferString("")
+	logger := log.NewLoggerFactory(log.InfoLevel, log.SetOut(buffer)).Logger(context.TODO())
+
+	loggers := map[string]Middleware{
+		"RequestLogger":  NewRequestLogger(logger).WithPolicy(ErrorsOnly()),
+		"ResponseLogger": NewResponseLogger(logger).WithPolicy(ErrorsOnly()),
+		"ExchangeLogger": NewExchangeLogger(logger).WithPolicy(ErrorsOnly()),
+		"Streaming":      NewRequestLogger(logger).WithPolicy(ErrorsOnly()).WithBodyPolicy(NewBodyPolicy().WithStreaming()),
+	}
+
+	for name, middleware := range loggers {
+		t.Run(name, func(t *testing.T) {
+			client := &http.Client{Transport: WithMiddleware(nil, middleware)}
+
+			response, err := client.Post(server.URL+"/ok", "text/plain", strings.NewReader("request"))
+			assert.NoError(t, err)
+			ioutil.ReadAll(response.Body)
+			response.Body.Close()
+			assert.Empty(t, buffer.String())
+
+			response, err = client.Post(server.URL+"/fail", "text/plain", strings.NewReader("request"))
+			assert.NoError(t, err)
+			ioutil.ReadAll(response.Body)
+			response.Body.Close()
+			assert.Equal(t, 1, strings.Count(strings.TrimSpace(buffer.String()), "\n")+1)
+			assert.Contains(t, buffer.String(), "/fail")
+
+			buffer.Reset()
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/best-expendables/httpclient/net/transport"
+)
 
 // Middleware for middleware as structure
 type Middleware interface {
@@ -14,16 +18,36 @@ func (f RoundTripperFn) RoundTrip(request *http.Request) (*http.Response, error)
 	return f(request)
 }
 
+// chain round tripper built by WithMiddleware, keeps the transport and the middlewares for Rewrap
+type chain struct {
+	http.RoundTripper
+	transport   http.RoundTripper
+	middlewares []Middleware
+}
+
 // WithMiddleware general way.
-// For flexibility use middleware.Container
+// For flexibility use middleware.Container.
+// For nil round tripper new transport with own connection pool is created, see transport.DefaultConfig
 func WithMiddleware(rt http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
 	if rt == nil {
-		rt = http.DefaultTransport
+		rt = transport.NewDefault()
 	}
 
+	wrapped := rt
 	for _, middleware := range middlewares {
-		rt = middleware.RoundTripper(rt)
+		wrapped = middleware.RoundTripper(wrapped)
+	}
+
+	return &chain{RoundTripper: wrapped, transport: rt, middlewares: middlewares}
+}
+
+// Rewrap builds the middlewares of the WithMiddleware round tripper again over the transport replaced by fn,
+// e.g. over a copy of the transport with other settings. False for round trippers not built by WithMiddleware.
+func Rewrap(rt http.RoundTripper, fn func(transport http.RoundTripper) http.RoundTripper) (http.RoundTripper, bool) {
+	c, ok := rt.(*chain)
+	if !ok {
+		return nil, false
 	}
 
-	return rt
+	return WithMiddleware(fn(c.transport), c.middlewares...), true
 }
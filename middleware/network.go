@@ -6,14 +6,37 @@ import (
 )
 
 // NetworkProfiler middleware
-type NetworkProfiler struct{}
+type NetworkProfiler struct {
+	collector *profile.Collector
+	keyFn     profile.KeyFunc
+}
 
 func NewNetworkProfiler() *NetworkProfiler {
 	return new(NetworkProfiler)
 }
 
-func (NetworkProfiler) RoundTripper(next http.RoundTripper) http.RoundTripper {
+// WithCollector aggregates reports of the completed requests, grouped by keyFn (by host if nil)
+func (p *NetworkProfiler) WithCollector(collector *profile.Collector, keyFn profile.KeyFunc) *NetworkProfiler {
+	if keyFn == nil {
+		keyFn = profile.HostKey
+	}
+	p.collector = collector
+	p.keyFn = keyFn
+	return p
+}
+
+func (p NetworkProfiler) RoundTripper(next http.RoundTripper) http.RoundTripper {
 	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
-		return next.RoundTrip(profile.Observe(request))
+		request = profile.Observe(request)
+		response, err := next.RoundTrip(request)
+
+		report := profile.ReportFromContext(request.Context())
+		report.Finish()
+
+		if p.collector != nil && err == nil {
+			p.collector.Add(p.keyFn(request), report)
+		}
+
+		return response, err
 	})
 }
@@ -31,3 +31,28 @@ func TestNetworkProfiler(t *testing.T) {
 	a.NotNil(report)
 	a.NotZero(report.ConnectionTime())
 }
+
+func TestNetworkProfiler_WithCollector(t *testing.T) {
+	a := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	collector := profile.NewCollector(10)
+	client := &http.Client{
+		Transport: WithMiddleware(nil, NewNetworkProfiler().WithCollector(collector, nil)),
+	}
+
+	for i := 0; i < 2; i++ {
+		res, err := client.Get(srv.URL)
+		a.NoError(err)
+		res.Body.Close()
+	}
+
+	stats := collector.Snapshot()
+	a.Len(stats, 1)
+	a.Equal(2, stats[0].Count)
+	a.Equal(0.5, stats[0].ReuseRatio)
+}
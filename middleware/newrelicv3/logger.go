@@ -0,0 +1,48 @@
+package newrelicv3
+
+import log "github.com/best-expendables/logger"
+
+// Logger writes messages of the agent with the logger.
+// The agent v3 does not return errors of the segments (e.g. a segment which can not be ended),
+// they are written only to the logger of the application configuration:
+//
+//	app, err := newrelic.NewApplication(
+//		newrelic.ConfigAppName("service"),
+//		newrelic.ConfigLogger(newrelicv3.NewLogger(logger)),
+//	)
+type Logger struct {
+	entry log.Entry
+}
+
+// NewLogger creates the agent logger, debug messages are not written
+func NewLogger(entry log.Entry) *Logger {
+	return &Logger{entry: entry}
+}
+
+func (l *Logger) Error(msg string, context map[string]interface{}) {
+	l.withFields(context).Error(msg)
+}
+
+func (l *Logger) Warn(msg string, context map[string]interface{}) {
+	l.withFields(context).Warning(msg)
+}
+
+func (l *Logger) Info(msg string, context map[string]interface{}) {
+	l.withFields(context).Info(msg)
+}
+
+func (l *Logger) Debug(msg string, context map[string]interface{}) {}
+
+func (l *Logger) DebugEnabled() bool {
+	return false
+}
+
+func (l *Logger) withFields(context map[string]interface{}) log.Entry {
+	fields := make(log.Fields, len(context)+1)
+	for key, value := range context {
+		fields[key] = value
+	}
+	fields["component"] = "httpclient.newrelicv3"
+
+	return l.entry.WithFields(fields)
+}
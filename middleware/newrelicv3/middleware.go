@@ -0,0 +1,89 @@
+package newrelicv3
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/best-expendables/httpclient/middleware"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type (
+	// Newrelic middleware for the New Relic Go agent v3.
+	// It starts an external segment, injects distributed tracing headers
+	// and records the response code and transport errors on the segment.
+	Newrelic struct {
+		urlFormatter middleware.URLFormatFunc
+
+		app                 *newrelic.Application
+		transactionNameFunc TransactionNameFunc
+	}
+
+	// TransactionNameFunc creates a name for the fallback transaction
+	TransactionNameFunc func(r *http.Request) string
+)
+
+// New creates middleware, urlFormatter is optional and groups external calls, see middleware.NewURLFormatFunc
+func New(urlFormatter middleware.URLFormatFunc) *Newrelic {
+	return &Newrelic{
+		urlFormatter:        urlFormatter,
+		transactionNameFunc: TransactionNameFromRequest,
+	}
+}
+
+// WithApplication enables the no-transaction fallback mode:
+// a new transaction is started for requests without a transaction in the context
+func (n *Newrelic) WithApplication(app *newrelic.Application) *Newrelic {
+	n.app = app
+	return n
+}
+
+// WithTransactionNameFunc sets a naming function for the fallback transaction
+func (n *Newrelic) WithTransactionNameFunc(fn TransactionNameFunc) *Newrelic {
+	n.transactionNameFunc = fn
+	return n
+}
+
+// RoundTripper middleware function
+func (n *Newrelic) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return middleware.RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		txn := newrelic.FromContext(request.Context())
+		if txn == nil && n.app != nil {
+			txn = n.app.StartTransaction(n.transactionNameFunc(request))
+			defer txn.End()
+			request = request.WithContext(newrelic.NewContext(request.Context(), txn))
+		}
+
+		if txn == nil {
+			return next.RoundTrip(request)
+		}
+
+		// StartExternalSegment writes distributed tracing headers into the request
+		request = middleware.CloneRequest(request)
+		segment := newrelic.StartExternalSegment(txn, request)
+		if n.urlFormatter != nil {
+			// URL has priority over Request, the request still provides the method of the call
+			segment.URL = n.urlFormatter(request)
+		}
+
+		response, err := next.RoundTrip(request)
+		if err != nil {
+			// Transport error of the call, the transaction itself has not failed
+			segment.AddAttribute("error.class", fmt.Sprintf("%T", err))
+			segment.AddAttribute("error.message", err.Error())
+		}
+		segment.Response = response
+		// Errors of the segment are written to the logger of the application, see NewLogger
+		segment.End()
+
+		return response, err
+	})
+}
+
+// TransactionNameFromRequest default name of the fallback transaction
+//
+// E.g.:
+//	-	out: External/example.com
+func TransactionNameFromRequest(r *http.Request) string {
+	return "External/" + r.URL.Host
+}
@@ -0,0 +1,201 @@
+package newrelicv3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/best-expendables/httpclient/middleware"
+	log "github.com/best-expendables/logger"
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/stretchr/testify/assert"
+)
+
+func newApplication(t *testing.T) *newrelic.Application {
+	app, err := newrelic.NewApplication(
+		newrelic.ConfigAppName("httpclient"),
+		newrelic.ConfigEnabled(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+// newConnectedApplication application connected to the fake collector,
+// agent attributes of the harvested span events are sent to the channel
+func newConnectedApplication(t *testing.T, options ...newrelic.ConfigOption) (*newrelic.Application, <-chan map[string]interface{}) {
+	spans := make(chan map[string]interface{}, 10)
+	collector := middleware.RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+		body := `{"return_value":null}`
+		switch r.URL.Query().Get("method") {
+		case "preconnect":
+			body = `{"return_value":{"redirect_host":"collector.test"}}`
+		case "connect":
+			body = `{"return_value":{"agent_run_id":"1","account_id":"123","trusted_account_key":"123","primary_application_id":"456"}}`
+		case "span_event_data":
+			var reader io.Reader = r.Body
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				reader, _ = gzip.NewReader(r.Body)
+			}
+			// [run ID, reservoir, [[intrinsics, user attributes, agent attributes], ...]]
+			var payload []json.RawMessage
+			var events [][]map[string]interface{}
+			content, _ := ioutil.ReadAll(reader)
+			if err := json.Unmarshal(content, &payload); err == nil && len(payload) == 3 {
+				json.Unmarshal(payload[2], &events)
+			}
+			for _, event := range events {
+				if event[0]["category"] == "http" {
+					spans <- event[2]
+				}
+			}
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Request: r}, nil
+	})
+
+	options = append([]newrelic.ConfigOption{
+		newrelic.ConfigAppName("httpclient"),
+		newrelic.ConfigLicense(strings.Repeat("0", 40)),
+		newrelic.ConfigDistributedTracerEnabled(true),
+		func(config *newrelic.Config) {
+			config.Transport = collector
+		},
+	}, options...)
+	app, err := newrelic.NewApplication(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.WaitForConnection(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	return app, spans
+}
+
+func TestNewrelic_WithoutTransaction(t *testing.T) {
+	a := assert.New(t)
+
+	transport := New(middleware.NewURLFormatFunc()).RoundTripper(
+		middleware.RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+			a.Nil(newrelic.FromContext(r.Context()))
+			return &http.Response{StatusCode: http.StatusOK, Request: r}, nil
+		}),
+	)
+
+	request, _ := http.NewRequest(http.MethodGet, "http://example.com/v1/users/1", nil)
+	response, err := transport.RoundTrip(request)
+	a.NoError(err)
+	a.Equal(http.StatusOK, response.StatusCode)
+}
+
+func TestNewrelic_WithTransaction(t *testing.T) {
+	a := assert.New(t)
+
+	txn := newApplication(t).StartTransaction("test")
+	defer txn.End()
+
+	transport := New(middleware.NewURLFormatFunc()).RoundTripper(
+		middleware.RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+			r.Header.Set("X-Modified", "1")
+			return nil, errors.New("connection refused")
+		}),
+	)
+
+	request, _ := http.NewRequest(http.MethodGet, "http://example.com/v1/users/1", nil)
+	request = request.WithContext(newrelic.NewContext(request.Context(), txn))
+
+	_, err := transport.RoundTrip(request)
+	a.EqualError(err, "connection refused")
+	a.Empty(request.Header.Get("X-Modified"), "Original request has been changed")
+}
+
+func TestNewrelic_FallbackTransaction(t *testing.T) {
+	a := assert.New(t)
+
+	var name string
+	transport := New(nil).
+		WithApplication(newApplication(t)).
+		WithTransactionNameFunc(func(r *http.Request) string {
+			name = TransactionNameFromRequest(r)
+			return name
+		}).
+		RoundTripper(middleware.RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+			a.NotNil(newrelic.FromContext(r.Context()))
+			return &http.Response{StatusCode: http.StatusNoContent, Request: r}, nil
+		}))
+
+	request, _ := http.NewRequest(http.MethodGet, "http://example.com/v1/users/1", nil)
+	_, err := transport.RoundTrip(request)
+	a.NoError(err)
+	a.Equal("External/example.com", name)
+}
+
+func TestNewrelic_DistributedTracing(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.NotEmpty(r.Header.Get("newrelic"), "distributed tracing header")
+		a.NotEmpty(r.Header.Get("traceparent"), "W3C trace context header")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	app, spans := newConnectedApplication(t)
+	txn := app.StartTransaction("test")
+
+	transport := New(func(r *http.Request) string {
+		return r.URL.Scheme + "://" + r.URL.Host + "/v1/users"
+	}).RoundTripper(http.DefaultTransport)
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/users/1", nil)
+	request = request.WithContext(newrelic.NewContext(request.Context(), txn))
+	response, err := transport.RoundTrip(request)
+	a.NoError(err)
+	response.Body.Close()
+	a.Empty(request.Header.Get("newrelic"), "Original request has been changed")
+
+	txn.End()
+	app.Shutdown(time.Second)
+
+	select {
+	case span := <-spans:
+		a.Equal(server.URL+"/v1/users", span["http.url"])
+		a.Equal(http.MethodGet, span["http.method"])
+		a.Equal(float64(http.StatusNoContent), span["http.statusCode"])
+	default:
+		a.Fail("external segment has not been recorded")
+	}
+}
+
+func TestNewrelic_SegmentError(t *testing.T) {
+	a := assert.New(t)
+
+	buffer := bytes.NewBufferString("")
+	logger := log.NewLoggerFactory(log.InfoLevel, log.SetOut(buffer)).Logger(context.TODO())
+	app, _ := newConnectedApplication(t, newrelic.ConfigLogger(NewLogger(logger)))
+	defer app.Shutdown(time.Second)
+
+	txn := app.StartTransaction("test")
+	transport := New(nil).RoundTripper(middleware.RoundTripperFn(func(r *http.Request) (*http.Response, error) {
+		// The transaction has ended before the segment
+		txn.End()
+		return &http.Response{StatusCode: http.StatusOK, Request: r}, nil
+	}))
+
+	request, _ := http.NewRequest(http.MethodGet, "http://example.com/v1/users/1", nil)
+	request = request.WithContext(newrelic.NewContext(request.Context(), txn))
+	_, err := transport.RoundTrip(request)
+	a.NoError(err)
+
+	a.Contains(buffer.String(), "unable to end external segment")
+	a.Contains(buffer.String(), "httpclient.newrelicv3")
+}
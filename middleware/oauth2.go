@@ -0,0 +1,322 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultOAuth2ExpiryDelta token is refreshed this time before its expiration
+	DefaultOAuth2ExpiryDelta = 30 * time.Second
+	// DefaultOAuth2FetchTimeout timeout of the token request when HTTPClient has no timeout
+	DefaultOAuth2FetchTimeout = 10 * time.Second
+)
+
+type (
+	// OAuth2Config client configuration for the token endpoint
+	OAuth2Config struct {
+		TokenURL     string
+		ClientID     string
+		ClientSecret string
+		// Scopes default scopes, can be overridden per route with OAuth2.WithRouteScopes
+		Scopes []string
+		// Audience default audience (RFC 8707 / Auth0 "audience" parameter)
+		Audience string
+		// RefreshToken uses "refresh_token" grant instead of "client_credentials".
+		// The refresh token is dropped upon "invalid_grant" error and "client_credentials" grant is used.
+		RefreshToken string
+		// ClientAuthInBody sends client credentials in the form body instead of the basic authentication
+		ClientAuthInBody bool
+		// ExpiryDelta, DefaultOAuth2ExpiryDelta if zero
+		ExpiryDelta time.Duration
+		// HTTPClient for the token endpoint, http.DefaultClient if nil.
+		// Its Timeout (DefaultOAuth2FetchTimeout if zero) limits the token request.
+		HTTPClient *http.Client
+	}
+
+	// OAuth2Token token from the token endpoint
+	OAuth2Token struct {
+		AccessToken  string    `json:"access_token"`
+		TokenType    string    `json:"token_type"`
+		RefreshToken string    `json:"refresh_token"`
+		ExpiresIn    int64     `json:"expires_in"`
+		Expiry       time.Time `json:"-"`
+	}
+
+	// OAuth2Error error response of the token endpoint
+	OAuth2Error struct {
+		StatusCode  int
+		Code        string `json:"error"`
+		Description string `json:"error_description"`
+	}
+
+	// OAuth2RouteFunc returns scopes and audience for the request
+	OAuth2RouteFunc func(r *http.Request) (scopes []string, audience string)
+
+	// OAuth2 obtains, caches and refreshes tokens via client credentials or refresh token grants.
+	// Request is retried once with a fresh token upon 401 response.
+	OAuth2 struct {
+		config  OAuth2Config
+		routeFn OAuth2RouteFunc
+
+		mu       sync.Mutex
+		tokens   map[string]*OAuth2Token
+		inflight map[string]*oauth2Call
+	}
+
+	oauth2Call struct {
+		done  chan struct{}
+		token *OAuth2Token
+		err   error
+	}
+)
+
+func (e *OAuth2Error) Error() string {
+	return fmt.Sprintf("oauth2 token error. Code: %v error: %s detail: %s", e.StatusCode, e.Code, e.Description)
+}
+
+// Valid token is not empty and not expired (with the expiry delta)
+func (t *OAuth2Token) valid(delta time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(delta).Before(t.Expiry)
+}
+
+// copy of the token for the caller, nil for nil token
+func (t *OAuth2Token) copy() *OAuth2Token {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
+
+// NewOAuth2 creates middleware
+func NewOAuth2(config OAuth2Config) *OAuth2 {
+	if config.ExpiryDelta == 0 {
+		config.ExpiryDelta = DefaultOAuth2ExpiryDelta
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+
+	return &OAuth2{
+		config:   config,
+		tokens:   make(map[string]*OAuth2Token),
+		inflight: make(map[string]*oauth2Call),
+	}
+}
+
+// WithRouteScopes sets scopes and audience per request
+func (o *OAuth2) WithRouteScopes(fn OAuth2RouteFunc) *OAuth2 {
+	o.routeFn = fn
+	return o
+}
+
+func (o *OAuth2) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		scopes, audience := o.config.Scopes, o.config.Audience
+		if o.routeFn != nil {
+			scopes, audience = o.routeFn(request)
+		}
+
+		token, err := o.Token(request.Context(), scopes, audience)
+		if err != nil {
+			return nil, err
+		}
+
+		// Body has to be replayed upon 401 response
+		var body []byte
+		if request.Body != nil && request.GetBody == nil {
+			if body, err = ioutil.ReadAll(request.Body); err != nil {
+				return nil, err
+			}
+			recoverRequestBody(request, body)
+		}
+
+		response, err := next.RoundTrip(authorize(request, token))
+		if err != nil || response.StatusCode != http.StatusUnauthorized {
+			return response, err
+		}
+
+		o.invalidate(oauth2Key(scopes, audience), token)
+		token, err = o.Token(request.Context(), scopes, audience)
+		if err != nil {
+			return response, nil
+		}
+
+		retry := authorize(request, token)
+		if request.GetBody != nil {
+			if retry.Body, err = request.GetBody(); err != nil {
+				return response, nil
+			}
+		} else {
+			recoverRequestBody(retry, body)
+		}
+
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+
+		return next.RoundTrip(retry)
+	})
+}
+
+// Token returns a copy of the cached token or obtains a new one.
+// Concurrent calls for the same scopes and audience share a single request to the token endpoint,
+// the request is not canceled with the context of the caller, ctx only limits the waiting.
+func (o *OAuth2) Token(ctx context.Context, scopes []string, audience string) (*OAuth2Token, error) {
+	key := oauth2Key(scopes, audience)
+
+	o.mu.Lock()
+	if token := o.tokens[key]; token.valid(o.config.ExpiryDelta) {
+		o.mu.Unlock()
+		return token.copy(), nil
+	}
+	call, ok := o.inflight[key]
+	if !ok {
+		// The cached token keeps the refresh token, it is empty once the refresh token has been dropped
+		refreshToken := o.config.RefreshToken
+		if token, ok := o.tokens[key]; ok {
+			refreshToken = token.RefreshToken
+		}
+
+		call = &oauth2Call{done: make(chan struct{})}
+		o.inflight[key] = call
+		go o.fill(key, call, scopes, audience, refreshToken)
+	}
+	o.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token.copy(), call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fill obtains the token for the shared call on a detached context
+func (o *OAuth2) fill(key string, call *oauth2Call, scopes []string, audience, refreshToken string) {
+	timeout := o.config.HTTPClient.Timeout
+	if timeout == 0 {
+		timeout = DefaultOAuth2FetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	call.token, call.err = o.fetch(ctx, scopes, audience, refreshToken)
+	dropped := false
+	if tokenErr, ok := call.err.(*OAuth2Error); ok && refreshToken != "" && tokenErr.Code == "invalid_grant" {
+		// Refresh token has expired or has been revoked, client credentials are used instead
+		dropped = true
+		call.token, call.err = o.fetch(ctx, scopes, audience, "")
+	}
+
+	o.mu.Lock()
+	if call.err == nil {
+		o.tokens[key] = call.token
+	} else if dropped {
+		o.tokens[key] = &OAuth2Token{}
+	}
+	delete(o.inflight, key)
+	o.mu.Unlock()
+	close(call.done)
+}
+
+// invalidate removes the token from the cache unless it has been already refreshed
+func (o *OAuth2) invalidate(key string, token *OAuth2Token) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if cached := o.tokens[key]; cached != nil && cached.AccessToken == token.AccessToken {
+		// Keep refresh token for the next grant
+		o.tokens[key] = &OAuth2Token{RefreshToken: token.RefreshToken}
+	}
+}
+
+func (o *OAuth2) fetch(ctx context.Context, scopes []string, audience, refreshToken string) (*OAuth2Token, error) {
+	form := url.Values{}
+	if refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+	if audience != "" {
+		form.Set("audience", audience)
+	}
+	if o.config.ClientAuthInBody {
+		form.Set("client_id", o.config.ClientID)
+		form.Set("client_secret", o.config.ClientSecret)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, o.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if !o.config.ClientAuthInBody {
+		request.SetBasicAuth(url.QueryEscape(o.config.ClientID), url.QueryEscape(o.config.ClientSecret))
+	}
+
+	response, err := o.config.HTTPClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		tokenErr := &OAuth2Error{StatusCode: response.StatusCode}
+		json.NewDecoder(response.Body).Decode(tokenErr)
+		return nil, tokenErr
+	}
+
+	token := new(OAuth2Token)
+	if err := json.NewDecoder(response.Body).Decode(token); err != nil {
+		return nil, fmt.Errorf("cannot decode oauth2 token: %s", err.Error())
+	}
+	if token.AccessToken == "" {
+		return nil, &OAuth2Error{StatusCode: response.StatusCode, Code: "invalid_token", Description: "empty access token"}
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+
+	return token, nil
+}
+
+func authorize(request *http.Request, token *OAuth2Token) *http.Request {
+	tokenType := token.TokenType
+	// RFC 6750 type is case-insensitive, some servers return "bearer"
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+
+	request = CloneRequest(request)
+	request.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+
+	return request
+}
+
+func oauth2Key(scopes []string, audience string) string {
+	sorted := append([]string(nil), scopes...)
+	sort.Strings(sorted)
+
+	return audience + "|" + strings.Join(sorted, " ")
+}
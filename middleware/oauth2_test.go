@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type OAuth2Suite struct {
+	suite.Suite
+
+	issued      int32
+	tokenServer *httptest.Server
+	grants      chan string
+}
+
+func (s *OAuth2Suite) SetupTest() {
+	s.issued = 0
+	s.grants = make(chan string, 100)
+	s.tokenServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		clientID, secret, _ := r.BasicAuth()
+		if clientID != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+		s.grants <- r.Form.Get("grant_type") + ":" + r.Form.Get("scope") + ":" + r.Form.Get("refresh_token")
+
+		// Slow token endpoint for the single-flight check
+		time.Sleep(10 * time.Millisecond)
+		n := atomic.AddInt32(&s.issued, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  fmt.Sprintf("token-%d", n),
+			"token_type":    "bearer",
+			"expires_in":    3600,
+			"refresh_token": fmt.Sprintf("refresh-%d", n),
+		})
+	}))
+}
+
+func (s *OAuth2Suite) TearDownTest() {
+	s.tokenServer.Close()
+}
+
+func (s *OAuth2Suite) config() OAuth2Config {
+	return OAuth2Config{
+		TokenURL:     s.tokenServer.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"orders:read"},
+	}
+}
+
+func (s *OAuth2Suite) TestSingleFlight() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("Bearer token-1", r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: WithMiddleware(nil, NewOAuth2(s.config()))}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.Get(srv.URL)
+			s.NoError(err)
+			s.Equal(http.StatusOK, response.StatusCode)
+		}()
+	}
+	wg.Wait()
+
+	s.Equal(int32(1), atomic.LoadInt32(&s.issued))
+	s.Equal("client_credentials:orders:read:", <-s.grants)
+}
+
+func (s *OAuth2Suite) TestRetryOnUnauthorized() {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.Equal(`{"id":1}`, string(body))
+
+		if atomic.AddInt32(&calls, 1) == 1 {
+			s.Equal("Bearer token-1", r.Header.Get("Authorization"))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.Equal("Bearer token-2", r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: WithMiddleware(nil, NewOAuth2(s.config()))}
+
+	response, err := client.Post(srv.URL, "application/json", ioutil.NopCloser(bytes.NewBufferString(`{"id":1}`)))
+	s.NoError(err)
+	s.Equal(http.StatusOK, response.StatusCode)
+	s.Equal(int32(2), calls)
+
+	s.Equal("client_credentials:orders:read:", <-s.grants)
+	s.Equal("refresh_token:orders:read:refresh-1", <-s.grants)
+}
+
+func (s *OAuth2Suite) TestRouteScopes() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	middleware := NewOAuth2(s.config()).WithRouteScopes(func(r *http.Request) ([]string, string) {
+		if r.Method == http.MethodGet {
+			return []string{"orders:read"}, "orders"
+		}
+		return []string{"orders:write"}, "orders"
+	})
+	client := &http.Client{Transport: WithMiddleware(nil, middleware)}
+
+	_, err := client.Get(srv.URL)
+	s.NoError(err)
+	_, err = client.Post(srv.URL, "", nil)
+	s.NoError(err)
+	_, err = client.Get(srv.URL)
+	s.NoError(err)
+
+	s.Equal(int32(2), atomic.LoadInt32(&s.issued))
+	s.Equal("client_credentials:orders:read:", <-s.grants)
+	s.Equal("client_credentials:orders:write:", <-s.grants)
+}
+
+func (s *OAuth2Suite) TestExpiry() {
+	middleware := NewOAuth2(s.config())
+
+	token, err := middleware.Token(context.Background(), nil, "")
+	s.NoError(err)
+	s.Equal("token-1", token.AccessToken)
+
+	// The caller gets a copy of the cached token
+	token.AccessToken = "modified"
+	token, err = middleware.Token(context.Background(), nil, "")
+	s.NoError(err)
+	s.Equal("token-1", token.AccessToken)
+
+	// Token expires within the expiry delta
+	middleware.tokens[oauth2Key(nil, "")].Expiry = time.Now().Add(DefaultOAuth2ExpiryDelta / 2)
+
+	token, err = middleware.Token(context.Background(), nil, "")
+	s.NoError(err)
+	s.Equal("token-2", token.AccessToken)
+}
+
+func (s *OAuth2Suite) TestInvalidGrant() {
+	var refreshed int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") == "refresh_token" {
+			atomic.AddInt32(&refreshed, 1)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"refresh token is expired"}`))
+			return
+		}
+		s.tokenServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer tokenServer.Close()
+
+	config := s.config()
+	config.TokenURL = tokenServer.URL
+	config.RefreshToken = "expired"
+	middleware := NewOAuth2(config)
+
+	token, err := middleware.Token(context.Background(), nil, "")
+	s.NoError(err)
+	s.Equal("token-1", token.AccessToken)
+	s.Equal("refresh-1", token.RefreshToken, "expired refresh token is dropped")
+	s.Equal("client_credentials::", <-s.grants)
+
+	// The refresh token of the response is rejected too, the next token is obtained by client credentials
+	middleware.invalidate(oauth2Key(nil, ""), token)
+	token, err = middleware.Token(context.Background(), nil, "")
+	s.NoError(err)
+	s.Equal("token-2", token.AccessToken)
+	s.Equal("client_credentials::", <-s.grants)
+	s.Equal(int32(2), atomic.LoadInt32(&refreshed))
+}
+
+func (s *OAuth2Suite) TestCanceledCaller() {
+	middleware := NewOAuth2(s.config())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := middleware.Token(ctx, nil, "")
+	s.Equal(context.Canceled, err)
+
+	// The shared request is not canceled with the first caller
+	token, err := middleware.Token(context.Background(), nil, "")
+	s.NoError(err)
+	s.Equal("token-1", token.AccessToken)
+	s.Equal(int32(1), atomic.LoadInt32(&s.issued))
+}
+
+func (s *OAuth2Suite) TestTokenError() {
+	config := s.config()
+	config.ClientSecret = "wrong"
+
+	client := &http.Client{Transport: WithMiddleware(nil, NewOAuth2(config))}
+
+	_, err := client.Get(s.tokenServer.URL)
+	s.Error(err)
+	s.Contains(err.Error(), "invalid_client")
+}
+
+func TestOAuth2Runner(t *testing.T) {
+	suite.Run(t, new(OAuth2Suite))
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+type propagationCtxKey int
+
+const propagatedValuesKey propagationCtxKey = iota
+
+// PropagatedValues inbound values (canonical header name => value) which should be forwarded downstream
+type PropagatedValues map[string]string
+
+// ContextWithPropagatedValues stores values for the Propagation middleware, keys are canonicalized
+func ContextWithPropagatedValues(ctx context.Context, values PropagatedValues) context.Context {
+	canonical := make(PropagatedValues, len(values))
+	for key, value := range values {
+		canonical[http.CanonicalHeaderKey(key)] = value
+	}
+
+	return context.WithValue(ctx, propagatedValuesKey, canonical)
+}
+
+// PropagatedValuesFromContext returns values stored by PropagationHandler or ContextWithPropagatedValues
+func PropagatedValuesFromContext(ctx context.Context) PropagatedValues {
+	if values, ok := ctx.Value(propagatedValuesKey).(PropagatedValues); ok {
+		return values
+	}
+
+	return nil
+}
+
+// PropagationHandler server-side companion of the Propagation middleware.
+// It captures the allow-listed headers of the inbound request and stores them into the request context.
+func PropagationHandler(next http.Handler, keys ...string) http.Handler {
+	keys = canonicalKeys(keys)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values := make(PropagatedValues, len(keys))
+		for _, key := range keys {
+			if value := r.Header.Get(key); value != "" {
+				values[key] = value
+			}
+		}
+
+		if len(values) > 0 {
+			r = r.WithContext(ContextWithPropagatedValues(r.Context(), values))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Propagation forwards allow-listed inbound values to the downstream request
+// as HTTP headers and/or OpenTracing baggage.
+// Generalisation of RequestID middleware.
+type Propagation struct {
+	keys    []string
+	header  bool
+	baggage bool
+}
+
+// NewPropagation creates middleware which forwards given keys as headers
+func NewPropagation(keys ...string) *Propagation {
+	return &Propagation{
+		keys:   canonicalKeys(keys),
+		header: true,
+	}
+}
+
+// WithHeaders enables or disables forwarding as HTTP headers
+func (p *Propagation) WithHeaders(flag bool) *Propagation {
+	p.header = flag
+	return p
+}
+
+// WithBaggage enables or disables forwarding as baggage of the span from the request context.
+// Baggage keys are lower-cased header names.
+func (p *Propagation) WithBaggage(flag bool) *Propagation {
+	p.baggage = flag
+	return p
+}
+
+func (p *Propagation) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		values := PropagatedValuesFromContext(request.Context())
+		if len(values) == 0 {
+			return next.RoundTrip(request)
+		}
+
+		if p.baggage {
+			if span := opentracing.SpanFromContext(request.Context()); span != nil {
+				for _, key := range p.keys {
+					if value, ok := values[key]; ok {
+						span.SetBaggageItem(strings.ToLower(key), value)
+					}
+				}
+			}
+		}
+
+		if p.header {
+			cloned := false
+			for _, key := range p.keys {
+				value, ok := values[key]
+				// Explicitly set header has priority over the inbound one
+				if !ok || request.Header.Get(key) != "" {
+					continue
+				}
+				if !cloned {
+					request = CloneRequest(request)
+					cloned = true
+				}
+				request.Header.Set(key, value)
+			}
+		}
+
+		return next.RoundTrip(request)
+	})
+}
+
+func canonicalKeys(keys []string) []string {
+	canonical := make([]string, 0, len(keys))
+	for _, key := range keys {
+		canonical = append(canonical, http.CanonicalHeaderKey(key))
+	}
+
+	return canonical
+}
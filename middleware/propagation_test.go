@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropagation(t *testing.T) {
+	a := assert.New(t)
+
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("4ac32f8f-e746-47f0-8e1b-898ae9f5b80c", r.Header.Get("X-LEL-User-ID"))
+		a.Equal("vn", r.Header.Get("X-Tenant"))
+		a.Empty(r.Header.Get("X-Secret"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer downstream.Close()
+
+	client := &http.Client{
+		Transport: WithMiddleware(nil, NewPropagation("x-lel-user-id", "X-Tenant")),
+	}
+
+	upstream := httptest.NewServer(PropagationHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, _ := http.NewRequest(http.MethodGet, downstream.URL, nil)
+		response, err := client.Do(request.WithContext(r.Context()))
+		a.NoError(err)
+		w.WriteHeader(response.StatusCode)
+	}), "X-LEL-User-ID", "X-Tenant"))
+	defer upstream.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, upstream.URL, nil)
+	request.Header.Set("X-LEL-User-ID", "4ac32f8f-e746-47f0-8e1b-898ae9f5b80c")
+	request.Header.Set("X-Tenant", "vn")
+	request.Header.Set("X-Secret", "secret")
+
+	response, err := http.DefaultClient.Do(request)
+	a.NoError(err)
+	a.Equal(http.StatusOK, response.StatusCode)
+}
+
+func TestPropagation_ExplicitHeaderHasPriority(t *testing.T) {
+	a := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("en", r.Header.Get("X-Locale"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{
+		Transport: WithMiddleware(nil, NewPropagation("X-Locale")),
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	request.Header.Set("X-Locale", "en")
+	ctx := ContextWithPropagatedValues(request.Context(), PropagatedValues{"X-Locale": "vi"})
+
+	_, err := client.Do(request.WithContext(ctx))
+	a.NoError(err)
+	a.Equal("en", request.Header.Get("X-Locale"))
+}
+
+func TestPropagation_Baggage(t *testing.T) {
+	a := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Empty(r.Header.Get("X-Tenant"))
+	}))
+	defer srv.Close()
+
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("root")
+
+	client := &http.Client{
+		Transport: WithMiddleware(nil, NewPropagation("X-Tenant").WithHeaders(false).WithBaggage(true)),
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	ctx := opentracing.ContextWithSpan(request.Context(), span)
+	ctx = ContextWithPropagatedValues(ctx, PropagatedValues{"X-Tenant": "vn"})
+
+	_, err := client.Do(request.WithContext(ctx))
+	a.NoError(err)
+	a.Equal("vn", span.BaggageItem("x-tenant"))
+}
+
+func TestContextWithPropagatedValues_CanonicalKeys(t *testing.T) {
+	a := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("vn", r.Header.Get("X-Tenant"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{
+		Transport: WithMiddleware(nil, NewPropagation("X-Tenant")),
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	ctx := ContextWithPropagatedValues(request.Context(), PropagatedValues{"x-tenant": "vn"})
+	a.Equal(PropagatedValues{"X-Tenant": "vn"}, PropagatedValuesFromContext(ctx))
+
+	_, err := client.Do(request.WithContext(ctx))
+	a.NoError(err)
+}
@@ -0,0 +1,352 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultRedactionMask replaces sensitive values in the logs
+const DefaultRedactionMask = "[REDACTED]"
+
+var (
+	// EmailPattern matches e-mail addresses
+	EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// SensitiveKeyPattern matches JSON keys, form fields and query parameters with credentials and card data
+	SensitiveKeyPattern = regexp.MustCompile(`(?i)^(password|passwd|secret|client_secret|token|access_token|refresh_token|id_token|api_key|apikey|card_number|cardnumber|pan|cvv|cvc)$`)
+
+	// panPattern 13-19 digits, optionally separated by spaces or dashes
+	panPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	// jsonPairPattern "key": value pair with scalar value, the string value of the truncated body can be unterminated
+	jsonPairPattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"\s*:\s*("(?:[^"\\]|\\.)*"?|[^\s,{}\[\]"][^\s,}\]]*)`)
+)
+
+// Redactor masks sensitive data in the logged headers, URL and body.
+// Methods of nil Redactor return the data as is.
+type Redactor struct {
+	mask        string
+	headers     map[string]bool
+	keys        *regexp.Regexp
+	jsonPaths   [][]string
+	formFields  map[string]bool
+	queryParams map[string]bool
+	patterns    []*regexp.Regexp
+	pan         bool
+}
+
+// NewRedactor creates redactor without rules
+func NewRedactor() *Redactor {
+	return &Redactor{
+		mask:        DefaultRedactionMask,
+		headers:     make(map[string]bool),
+		formFields:  make(map[string]bool),
+		queryParams: make(map[string]bool),
+	}
+}
+
+// DefaultRedactor masks credential headers (Authorization, cookies, API keys) and SensitiveKeyPattern keys
+func DefaultRedactor() *Redactor {
+	return NewRedactor().
+		WithHeaders("Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key").
+		WithKeyPattern(SensitiveKeyPattern)
+}
+
+// WithMask replacement of the sensitive values
+func (r *Redactor) WithMask(mask string) *Redactor {
+	r.mask = mask
+	return r
+}
+
+// WithHeaders masks the header values, the names are case-insensitive
+func (r *Redactor) WithHeaders(names ...string) *Redactor {
+	for _, name := range names {
+		r.headers[http.CanonicalHeaderKey(name)] = true
+	}
+	return r
+}
+
+// WithKeyPattern masks JSON keys, form fields and query parameters matching the pattern
+func (r *Redactor) WithKeyPattern(pattern *regexp.Regexp) *Redactor {
+	r.keys = pattern
+	return r
+}
+
+// WithJSONPaths masks JSON fields by dot separated path, "*" matches any key and arrays are traversed,
+// e.g. "user.password" or "cards.*.number"
+func (r *Redactor) WithJSONPaths(paths ...string) *Redactor {
+	for _, path := range paths {
+		r.jsonPaths = append(r.jsonPaths, strings.Split(path, "."))
+	}
+	return r
+}
+
+// WithFormFields masks the fields of form-urlencoded bodies, the names are case-sensitive
+func (r *Redactor) WithFormFields(names ...string) *Redactor {
+	for _, name := range names {
+		r.formFields[name] = true
+	}
+	return r
+}
+
+// WithQueryParams masks the query parameters of the logged URL, the names are case-sensitive
+func (r *Redactor) WithQueryParams(names ...string) *Redactor {
+	for _, name := range names {
+		r.queryParams[name] = true
+	}
+	return r
+}
+
+// WithPatterns scrubs matches in all logged values, e.g. EmailPattern
+func (r *Redactor) WithPatterns(patterns ...*regexp.Regexp) *Redactor {
+	r.patterns = append(r.patterns, patterns...)
+	return r
+}
+
+// WithPANScrubbing scrubs payment card numbers (validated by Luhn checksum) in all logged values
+func (r *Redactor) WithPANScrubbing() *Redactor {
+	r.pan = true
+	return r
+}
+
+// Header returns a copy of the header with masked values
+func (r *Redactor) Header(header http.Header) http.Header {
+	if r == nil || header == nil {
+		return header
+	}
+
+	redacted := make(http.Header, len(header))
+	for name, values := range header {
+		// Header set directly by the map can have a non-canonical name
+		if r.headers[http.CanonicalHeaderKey(name)] {
+			redacted[name] = []string{r.mask}
+			continue
+		}
+		scrubbed := make([]string, len(values))
+		for i, value := range values {
+			scrubbed[i] = r.scrub(value)
+		}
+		redacted[name] = scrubbed
+	}
+	return redacted
+}
+
+// URL string with masked password and query parameters, empty for nil URL
+func (r *Redactor) URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	if r == nil {
+		return u.String()
+	}
+
+	redacted := *u
+	if _, ok := u.User.Password(); ok {
+		redacted.User = url.UserPassword(u.User.Username(), r.mask)
+	}
+	if u.RawQuery != "" {
+		redacted.RawQuery = r.query(u.RawQuery)
+	}
+
+	return redacted.String()
+}
+
+// Form returns a copy of the values with masked fields
+func (r *Redactor) Form(values url.Values) url.Values {
+	if r == nil {
+		return values
+	}
+
+	redacted := make(url.Values, len(values))
+	for name, fieldValues := range values {
+		if r.formFields[name] || r.sensitiveKey(name) {
+			redacted[name] = []string{r.mask}
+			continue
+		}
+		scrubbed := make([]string, len(fieldValues))
+		for i, value := range fieldValues {
+			scrubbed[i] = r.scrub(value)
+		}
+		redacted[name] = scrubbed
+	}
+	return redacted
+}
+
+// Body masks JSON fields, other bodies are scrubbed by the patterns.
+// JSON body is re-encoded only when something has been masked.
+func (r *Redactor) Body(body string) string {
+	if r == nil || body == "" {
+		return body
+	}
+
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		decoder := json.NewDecoder(strings.NewReader(trimmed))
+		decoder.UseNumber()
+
+		var value interface{}
+		if err := decoder.Decode(&value); err == nil && !decoder.More() {
+			redacted, masked := r.json(value, nil)
+			if !masked {
+				return body
+			}
+
+			buf := bytes.Buffer{}
+			encoder := json.NewEncoder(&buf)
+			encoder.SetEscapeHTML(false)
+			if err := encoder.Encode(redacted); err == nil {
+				return strings.TrimRight(buf.String(), "\n")
+			}
+		}
+
+		return r.scrub(r.partialJSON(body))
+	}
+
+	return r.scrub(body)
+}
+
+// partialJSON masks values of the invalid (e.g. truncated) JSON by the key,
+// only the last segment of the JSON paths can be matched
+func (r *Redactor) partialJSON(body string) string {
+	redacted := strings.Builder{}
+	last := 0
+	for _, match := range jsonPairPattern.FindAllStringSubmatchIndex(body, -1) {
+		key := body[match[2]:match[3]]
+		if !r.sensitiveKey(key) && !r.matchesLastSegment(key) {
+			continue
+		}
+		redacted.WriteString(body[last:match[4]])
+		redacted.WriteString(`"` + r.mask + `"`)
+		last = match[5]
+	}
+	redacted.WriteString(body[last:])
+
+	return redacted.String()
+}
+
+func (r *Redactor) matchesLastSegment(key string) bool {
+	for _, pattern := range r.jsonPaths {
+		if segment := pattern[len(pattern)-1]; segment != "*" && segment == key {
+			return true
+		}
+	}
+	return false
+}
+
+// json masks the value in place, reports whether anything has been masked
+func (r *Redactor) json(value interface{}, path []string) (interface{}, bool) {
+	masked := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := append(path[:len(path):len(path)], key)
+			if r.sensitiveKey(key) || r.matchesPath(childPath) {
+				v[key] = r.mask
+				masked = true
+				continue
+			}
+			redacted, childMasked := r.json(child, childPath)
+			v[key] = redacted
+			masked = masked || childMasked
+		}
+	case []interface{}:
+		for i, child := range v {
+			redacted, childMasked := r.json(child, path)
+			v[i] = redacted
+			masked = masked || childMasked
+		}
+	case string:
+		if scrubbed := r.scrub(v); scrubbed != v {
+			return scrubbed, true
+		}
+	case json.Number:
+		if scrubbed := r.scrub(v.String()); scrubbed != v.String() {
+			return scrubbed, true
+		}
+	}
+	return value, masked
+}
+
+func (r *Redactor) matchesPath(path []string) bool {
+	for _, pattern := range r.jsonPaths {
+		if len(pattern) != len(path) {
+			continue
+		}
+		matched := true
+		for i, segment := range pattern {
+			if segment != "*" && segment != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// query masks parameters keeping the original order and encoding
+func (r *Redactor) query(rawQuery string) string {
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		key, err := url.QueryUnescape(parts[0])
+		if err != nil || len(parts) == 1 {
+			continue
+		}
+		if r.queryParams[key] || r.sensitiveKey(key) {
+			pairs[i] = parts[0] + "=" + r.mask
+			continue
+		}
+		if value, err := url.QueryUnescape(parts[1]); err == nil {
+			if scrubbed := r.scrub(value); scrubbed != value {
+				pairs[i] = parts[0] + "=" + url.QueryEscape(scrubbed)
+			}
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func (r *Redactor) sensitiveKey(key string) bool {
+	return r.keys != nil && r.keys.MatchString(key)
+}
+
+// scrub replaces pattern matches and card numbers
+func (r *Redactor) scrub(value string) string {
+	for _, pattern := range r.patterns {
+		value = pattern.ReplaceAllLiteralString(value, r.mask)
+	}
+	if r.pan {
+		value = panPattern.ReplaceAllStringFunc(value, func(match string) string {
+			if luhnValid(match) {
+				return r.mask
+			}
+			return match
+		})
+	}
+	return value
+}
+
+// luhnValid checks the card number checksum, separators are ignored
+func luhnValid(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -2,60 +2,115 @@ package middleware
 
 import (
 	"github.com/best-expendables/httpclient/net"
+	"bytes"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 )
 
-// Request entry
-type requestEntry struct {
-	Header     http.Header
-	Method     string
-	Body       string
-	binaryBody bool
-}
+const formContentType = "application/x-www-form-urlencoded"
+
+type (
+	// Request entry
+	requestEntry struct {
+		Header     http.Header
+		Method     string
+		Body       string
+		binaryBody bool
+	}
+
+	// remainingBody reads the bytes read by the logger first, then the rest of the original body
+	remainingBody struct {
+		io.Reader
+		io.Closer
+	}
+)
 
-func newRequestEntry(request *http.Request) (requestEntry, error) {
+func newRequestEntry(request *http.Request, redactor *Redactor, policy *BodyPolicy) (requestEntry, error) {
 	header := request.Header
 
 	requestEntry := requestEntry{
 		Method: request.Method,
-		Header: header,
+		Header: redactor.Header(header),
 	}
 
-	if request.Body == nil {
+	if request.Body == nil || policy.skip(request, header) {
 		return requestEntry, nil
 	}
 
+	if limit := policy.limit(); limit > 0 {
+		// Only the logged part of the body is read into memory
+		body, err := ioutil.ReadAll(io.LimitReader(request.Body, int64(limit)+1))
+		if len(body) > limit {
+			request.Body = &remainingBody{Reader: io.MultiReader(bytes.NewReader(body), request.Body), Closer: request.Body}
+			if err != nil {
+				return requestEntry, err
+			}
+
+			total := request.ContentLength
+			if total <= int64(limit) {
+				total = -1
+			}
+			requestEntry.setBody(header, body[:limit], total, redactor)
+
+			return requestEntry, nil
+		}
+
+		return requestEntry.setWholeBody(request, body, err, redactor)
+	}
+
 	body, err := ioutil.ReadAll(request.Body)
+
+	return requestEntry.setWholeBody(request, body, err, redactor)
+}
+
+// setWholeBody logs the body read to the end, the form body is logged as JSON
+func (e requestEntry) setWholeBody(request *http.Request, body []byte, err error, redactor *Redactor) (requestEntry, error) {
+	header := request.Header
 	defer recoverRequestBody(request, body)
 
 	if err != nil {
-		return requestEntry, err
+		return e, err
 	}
 
-	if net.HasBinaryContent(request.Header, body) {
-		requestEntry.binaryBody = true
+	if header.Get("Content-Type") == formContentType && !net.HasBinaryContent(header, body) {
+		e.Body = parseFormToJson(request, body, redactor)
 
-		return requestEntry, nil
+		return e, nil
 	}
 
-	if contentType := header.Get("Content-Type"); contentType == "application/x-www-form-urlencoded" {
-		requestEntry.Body = parseFormToJson(request, body)
+	e.setBody(header, body, int64(len(body)), redactor)
 
-		return requestEntry, nil
+	return e, nil
+}
+
+// setBody logs the captured part of the body with the truncation marker
+func (e *requestEntry) setBody(header http.Header, body []byte, total int64, redactor *Redactor) {
+	if net.HasBinaryContent(header, body) {
+		e.binaryBody = true
+
+		return
 	}
 
-	requestEntry.Body = string(body)
+	if contentType := header.Get("Content-Type"); contentType == formContentType {
+		// The last field of the truncated form can be incomplete
+		values, _ := url.ParseQuery(string(body))
+		fields, _ := json.Marshal(redactor.Form(values))
+		e.Body = string(fields) + truncationMark(len(body), total)
+
+		return
+	}
 
-	return requestEntry, nil
+	e.Body = redactor.Body(string(body)) + truncationMark(len(body), total)
 }
 
-func parseFormToJson(request *http.Request, body []byte) string {
+func parseFormToJson(request *http.Request, body []byte, redactor *Redactor) string {
 	recoverRequestBody(request, body)
 	request.ParseForm()
 
-	fields, _ := json.Marshal(request.Form)
+	fields, _ := json.Marshal(redactor.Form(request.Form))
 
 	return string(fields)
 }
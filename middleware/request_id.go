@@ -18,7 +18,7 @@ func NewRequestID() *RequestID {
 func (RequestID) RoundTripper(next http.RoundTripper) http.RoundTripper {
 	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
 		if requestID := trace.RequestIDFromContext(request.Context()); requestID != "" {
-			request = cloneRequest(request)
+			request = CloneRequest(request)
 			trace.RequestIDToHeader(request.Header, requestID)
 		}
 
@@ -2,55 +2,169 @@ package middleware
 
 import (
 	"net/http"
+	"sync"
+	"time"
 
 	log "github.com/best-expendables/logger"
 )
 
-// RequestLogger create log for request
-type RequestLogger struct {
-	logger
-}
+type (
+	// RequestLogger create log for request
+	RequestLogger struct {
+		logger
+		redactor   *Redactor
+		bodyPolicy *BodyPolicy
+		policy     LogPolicy
+	}
+
+	// deferredRecord is written when all the parts of the record are ready
+	deferredRecord struct {
+		mu      sync.Mutex
+		pending int
+		write   func()
+	}
+)
 
 // NewRequestLogger create logger for request
 func NewRequestLogger(loggerEntry log.Entry) *RequestLogger {
 	return &RequestLogger{
-		logger: logger{logger: loggerEntry},
+		logger:     logger{logger: loggerEntry},
+		redactor:   DefaultRedactor(),
+		bodyPolicy: NewBodyPolicy(),
 	}
 }
 
+// WithRedactor masks sensitive data in the logged request, DefaultRedactor is used by default, nil disables masking
+func (l *RequestLogger) WithRedactor(redactor *Redactor) *RequestLogger {
+	l.redactor = redactor
+	return l
+}
+
+// WithBodyPolicy limits the logged body, NewBodyPolicy is used by default, nil logs the whole body
+func (l *RequestLogger) WithBodyPolicy(policy *BodyPolicy) *RequestLogger {
+	l.bodyPolicy = policy
+	return l
+}
+
+// WithPolicy logs only the calls allowed by the policy, nil logs every call.
+// The request is logged after the response has been received to evaluate the policy.
+func (l *RequestLogger) WithPolicy(policy LogPolicy) *RequestLogger {
+	l.policy = policy
+	return l
+}
+
 func (l *RequestLogger) RoundTripper(next http.RoundTripper) http.RoundTripper {
 	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
-		if request != nil {
-			l.Process(request)
+		if request == nil {
+			return next.RoundTrip(request)
 		}
 
-		return next.RoundTrip(request)
+		if l.policy == nil {
+			return next.RoundTrip(l.process(request, l.bodyPolicy.stream()))
+		}
+
+		logger := l.logger.get(request.Context())
+		if logger == nil {
+			return next.RoundTrip(request)
+		}
+
+		var (
+			call     LogCall
+			entry    requestEntry
+			entryErr error
+		)
+		url := l.redactor.URL(request.URL)
+		record := &deferredRecord{pending: 2, write: func() {
+			if l.policy.ShouldLog(&call) {
+				l.write(logger, url, entry, entryErr)
+			}
+		}}
+
+		request = l.capture(request, l.bodyPolicy.stream(), func(requestEntry requestEntry, err error) {
+			entry, entryErr = requestEntry, err
+			record.done()
+		})
+
+		start := time.Now()
+		response, err := next.RoundTrip(request)
+		call = LogCall{Request: request, Response: response, Err: err, Duration: time.Since(start)}
+		record.done()
+
+		return response, err
 	})
 }
 
+// Process logs the request, the policy is evaluated without the response.
+// The logged part of the body is read also in the streaming mode, there is no transport to read it.
 func (l *RequestLogger) Process(request *http.Request) error {
+	l.process(request, false)
+
+	return nil
+}
+
+// process logs the request, returns the request to send
+func (l *RequestLogger) process(request *http.Request, stream bool) *http.Request {
 	logger := l.logger.get(request.Context())
 
-	if logger == nil {
-		return nil
+	if logger == nil || !shouldLog(l.policy, &LogCall{Request: request}) {
+		return request
 	}
 
-	requestEntry, err := newRequestEntry(request)
+	url := l.redactor.URL(request.URL)
+	return l.capture(request, stream, func(requestEntry requestEntry, err error) {
+		l.write(logger, url, requestEntry, err)
+	})
+}
+
+// capture reads the request entry, returns the request to send.
+// In the streaming mode the entry is ready when the body is read by the transport, the body is captured
+// on the copy of the request.
+func (l *RequestLogger) capture(request *http.Request, stream bool, ready func(requestEntry requestEntry, err error)) *http.Request {
+	if stream && request.Body != nil && request.Body != http.NoBody && !l.bodyPolicy.skip(request, request.Header) {
+		requestEntry := requestEntry{
+			Method: request.Method,
+			Header: l.redactor.Header(request.Header),
+		}
+		header := request.Header
+		request = CloneRequest(request)
+		request.Body = newCapturedBody(request.Body, l.bodyPolicy.limit(), func(body []byte, total int64, err error) {
+			requestEntry.setBody(header, body, total, l.redactor)
+			ready(requestEntry, err)
+		})
 
+		return request
+	}
+
+	ready(newRequestEntry(request, l.redactor, l.bodyPolicy))
+
+	return request
+}
+
+func (l *RequestLogger) write(logger log.Entry, url string, requestEntry requestEntry, err error) {
 	meta := log.Fields{
 		"request": requestEntry,
 		"source":  "RequestLogger",
-		"url":     request.URL.String(),
+		"url":     url,
 	}
 
 	if err != nil {
 		meta["err"] = err
 		logger.WithFields(meta).Warning("Request logger has an error")
 
-		return nil
+		return
 	}
 
 	logger.WithFields(meta).Info()
+}
 
-	return nil
+// done marks one part of the record as ready
+func (r *deferredRecord) done() {
+	r.mu.Lock()
+	r.pending--
+	ready := r.pending == 0
+	r.mu.Unlock()
+
+	if ready {
+		r.write()
+	}
 }
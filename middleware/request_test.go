@@ -20,7 +20,7 @@ func TestRequestEntry(t *testing.T) {
 	request, _ := http.NewRequest(http.MethodGet, server.URL, bytes.NewBufferString("test body"))
 	request.Header.Set("Test", "1")
 
-	requestEntry, err := newRequestEntry(request)
+	requestEntry, err := newRequestEntry(request, nil, nil)
 
 	read, _ := ioutil.ReadAll(request.Body)
 
@@ -65,7 +65,7 @@ func TestRestEntry_parseFormToJson(t *testing.T) {
 
 	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	parsedBody := parseFormToJson(request, bytes.NewBufferString(body).Bytes())
+	parsedBody := parseFormToJson(request, bytes.NewBufferString(body).Bytes(), nil)
 
 	if parsedBody != "{\"key1\":[\"value1\"],\"key2\":[\"value2\"]}" {
 		t.Error("expected body to be parsed correctly")
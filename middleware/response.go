@@ -3,6 +3,7 @@ package middleware
 import (
 	"github.com/best-expendables/httpclient/net"
 	"bytes"
+	"io"
 	"io/ioutil"
 	"net/http"
 )
@@ -15,32 +16,51 @@ type responseEntry struct {
 	binaryBody bool
 }
 
-func newResponseEntry(response *http.Response) (responseEntry, error) {
+func newResponseEntry(response *http.Response, redactor *Redactor, policy *BodyPolicy) (responseEntry, error) {
 	header := response.Header
 
 	responseEntry := responseEntry{
-		Header:     header,
+		Header:     redactor.Header(header),
 		StatusCode: response.StatusCode,
 	}
 
-	if response.Body == nil {
+	if response.Body == nil || policy.skip(response.Request, header) {
 		return responseEntry, nil
 	}
 
-	body, err := ioutil.ReadAll(response.Body)
-	response.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	limit := policy.limit()
+	reader := io.Reader(response.Body)
+	if limit > 0 {
+		// Only the logged part of the body is read into memory
+		reader = io.LimitReader(response.Body, int64(limit)+1)
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	response.Body = &remainingBody{Reader: io.MultiReader(bytes.NewReader(body), response.Body), Closer: response.Body}
 
 	if err != nil {
 		return responseEntry, err
 	}
 
-	if net.HasBinaryContent(response.Header, body) {
-		responseEntry.binaryBody = true
-
-		return responseEntry, nil
+	total := int64(len(body))
+	if limit > 0 && len(body) > limit {
+		total = response.ContentLength
+		if total <= int64(limit) {
+			total = -1
+		}
 	}
-
-	responseEntry.Body = string(body)
+	responseEntry.setBody(header, policy.truncate(body), total, redactor)
 
 	return responseEntry, nil
 }
+
+// setBody logs the captured part of the body with the truncation marker
+func (e *responseEntry) setBody(header http.Header, body []byte, total int64, redactor *Redactor) {
+	if net.HasBinaryContent(header, body) {
+		e.binaryBody = true
+
+		return
+	}
+
+	e.Body = redactor.Body(string(body)) + truncationMark(len(body), total)
+}
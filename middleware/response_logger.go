@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"github.com/best-expendables/httpclient/net"
 	"github.com/best-expendables/httpclient/net/profile"
 	"net/http"
+	"time"
 
 	log "github.com/best-expendables/logger"
 )
@@ -10,70 +12,193 @@ import (
 // ResponseLogger create log for response
 type ResponseLogger struct {
 	logger
+	redactor    *Redactor
+	bodyPolicy  *BodyPolicy
+	statusLevel StatusLevelFn
+	policy      LogPolicy
 }
 
 // NewResponseLogger create logger for response
 func NewResponseLogger(loggerEntry log.Entry) *ResponseLogger {
 	return &ResponseLogger{
-		logger: logger{logger: loggerEntry},
+		logger:      logger{logger: loggerEntry},
+		redactor:    DefaultRedactor(),
+		bodyPolicy:  NewBodyPolicy(),
+		statusLevel: DefaultStatusLevel,
 	}
 }
 
+// WithRedactor masks sensitive data in the logged response, DefaultRedactor is used by default, nil disables masking
+func (l *ResponseLogger) WithRedactor(redactor *Redactor) *ResponseLogger {
+	l.redactor = redactor
+	return l
+}
+
+// WithBodyPolicy limits the logged body, NewBodyPolicy is used by default, nil logs the whole body
+func (l *ResponseLogger) WithBodyPolicy(policy *BodyPolicy) *ResponseLogger {
+	l.bodyPolicy = policy
+	return l
+}
+
+// WithStatusLevel log level by the response status, DefaultStatusLevel is used by default
+func (l *ResponseLogger) WithStatusLevel(fn StatusLevelFn) *ResponseLogger {
+	l.statusLevel = fn
+	return l
+}
+
+// WithPolicy logs only the responses allowed by the policy, nil logs every response.
+// The policy is evaluated when the response headers are received, before the body is read.
+func (l *ResponseLogger) WithPolicy(policy LogPolicy) *ResponseLogger {
+	l.policy = policy
+	return l
+}
+
 func (l *ResponseLogger) RoundTripper(next http.RoundTripper) http.RoundTripper {
 	return RoundTripperFn(func(request *http.Request) (*http.Response, error) {
+		start := time.Now()
 		response, err := next.RoundTrip(request)
 		if response != nil {
-			l.Process(response)
+			l.process(response, time.Since(start))
+		} else if err != nil {
+			l.processError(request, err, time.Since(start))
 		}
 
 		return response, err
 	})
 }
 
+// processError logs the transport error (DNS, connection, TLS, timeout...) with the network profile collected so far
+func (l *ResponseLogger) processError(request *http.Request, err error, elapsed time.Duration) {
+	logger := l.logger.get(request.Context())
+
+	// Abandoned request (e.g. the losing hedging attempt) has not failed
+	if logger == nil || net.IsAbandoned(request.Context()) ||
+		!shouldLog(l.policy, &LogCall{Request: request, Err: err, Duration: elapsed}) {
+		return
+	}
+
+	meta := log.Fields{
+		"url":         l.redactor.URL(request.URL),
+		"source":      "ResponseLogger",
+		"err":         err.Error(),
+		"error_class": net.ClassifyRequestError(request.Context(), err),
+		"elapsed":     toMilliseconds(elapsed),
+	}
+
+	if report := profile.ReportFromContext(request.Context()); report != nil {
+		meta["network"] = partialNetworkFields(report)
+	}
+
+	logger.WithFields(meta).Error("Request has failed")
+}
+
+// Process logs the response, the policy is evaluated without the call duration
 func (l *ResponseLogger) Process(response *http.Response) error {
+	return l.process(response, 0)
+}
+
+func (l *ResponseLogger) process(response *http.Response, duration time.Duration) error {
 	logger := l.logger.get(response.Request.Context())
 
-	if logger == nil {
+	if logger == nil || !shouldLog(l.policy, &LogCall{Request: response.Request, Response: response, Duration: duration}) {
 		return nil
 	}
 
-	responseEntry, err := newResponseEntry(response)
+	if l.bodyPolicy.stream() && response.Body != nil && !l.bodyPolicy.skip(response.Request, response.Header) {
+		responseEntry := responseEntry{
+			Header:     l.redactor.Header(response.Header),
+			StatusCode: response.StatusCode,
+		}
+		response.Body = newCapturedBody(response.Body, l.bodyPolicy.limit(), func(body []byte, total int64, err error) {
+			responseEntry.setBody(response.Header, body, total, l.redactor)
+			l.write(logger, response, responseEntry, err)
+		})
+
+		return nil
+	}
+
+	responseEntry, err := newResponseEntry(response, l.redactor, l.bodyPolicy)
+	l.write(logger, response, responseEntry, err)
+
+	return nil
+}
 
+func (l *ResponseLogger) write(logger log.Entry, response *http.Response, responseEntry responseEntry, err error) {
 	meta := log.Fields{
-		"url":      response.Request.URL.String(),
+		"url":      l.redactor.URL(response.Request.URL),
 		"source":   "ResponseLogger",
 		"response": responseEntry,
 	}
 
 	if report := profile.ReportFromResponse(response); report != nil {
-		network := make(map[string]interface{})
-		network["reused"] = report.Reused
-
-		if !report.Reused {
-			network["dns"] = report.ConnectionTimeMs()
-			network["connection"] = report.ConnectionTimeMs()
-		}
-
-		meta["network"] = network
+		meta["network"] = networkFields(report)
 	}
 
 	if err != nil {
 		meta["err"] = err
 		logger.WithFields(meta).Warning("Response logger has an error")
 
-		return nil
+		return
 	}
 
-	entry := logger.WithFields(meta)
-
-	statusText := http.StatusText(response.StatusCode)
+	logWithLevel(logger.WithFields(meta), l.statusLevel(response.StatusCode), http.StatusText(response.StatusCode))
+}
 
-	// 422 response code uses for validation error
-	if response.StatusCode < 400 || response.StatusCode == http.StatusUnprocessableEntity {
-		entry.Info(statusText)
+func networkFields(report *profile.Report) map[string]interface{} {
+	network := make(map[string]interface{})
+	network["reused"] = report.Reused
+	network["remote_addr"] = report.RemoteAddr
+	network["wait"] = report.ConnectionWaitTimeMs()
+	network["ttfb"] = report.TimeToFirstByteMs()
+
+	if !report.Reused {
+		network["dns"] = report.DNSLookupTimeMs()
+		network["dns_cache"] = report.DNSCacheHit
+		network["connection"] = report.ConnectionTimeMs()
 	} else {
-		entry.Error(statusText)
+		network["idle"] = report.IdleTimeMs()
 	}
 
-	return nil
+	if report.TLSVersion != 0 {
+		network["tls_version"] = report.TLSVersionName()
+		network["tls_cipher_suite"] = report.TLSCipherSuiteName()
+		network["tls_resumed"] = report.TLSResumed
+		if !report.Reused {
+			network["tls_handshake"] = report.TLSHandshakeTimeMs()
+		}
+	}
+
+	if report.NegotiatedProtocol != "" {
+		network["protocol"] = report.NegotiatedProtocol
+	}
+
+	return network
+}
+
+// partialNetworkFields phases of the failed request which have been completed
+func partialNetworkFields(report *profile.Report) map[string]interface{} {
+	network := make(map[string]interface{})
+
+	if !report.DNSLookupDone.IsZero() {
+		network["dns"] = report.DNSLookupTimeMs()
+	}
+	if !report.ConnectDone.IsZero() {
+		network["connection"] = report.ConnectionTimeMs()
+	}
+	if !report.TLSHandshakeDone.IsZero() {
+		network["tls_handshake"] = report.TLSHandshakeTimeMs()
+	}
+	if !report.GotConn.IsZero() {
+		network["reused"] = report.Reused
+		network["remote_addr"] = report.RemoteAddr
+		network["wait"] = report.ConnectionWaitTimeMs()
+	}
+	if !report.WroteRequest.IsZero() {
+		network["write"] = report.WriteTimeMs()
+	}
+	if !report.FirstResponseByte.IsZero() {
+		network["ttfb"] = report.TimeToFirstByteMs()
+	}
+
+	return network
 }
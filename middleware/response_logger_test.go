@@ -3,12 +3,17 @@ package middleware
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"io/ioutil"
+	stdnet "net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"strings"
 
+	"github.com/best-expendables/httpclient/net/profile"
 	log "github.com/best-expendables/logger"
 	"github.com/stretchr/testify/suite"
 )
@@ -167,6 +172,75 @@ func (s *ResponseLoggerSuite) Test_LoggerWithProfiler() {
 	})
 }
 
+func (s *ResponseLoggerSuite) Test_TransportError() {
+	listener, _ := stdnet.Listen("tcp", "127.0.0.1:0")
+	address := listener.Addr().String()
+	listener.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	s.T().Run("Connection refused", func(t *testing.T) {
+		defer s.buffer.Reset()
+
+		client := &http.Client{
+			Transport: WithMiddleware(nil, NewResponseLogger(s.logger), NewNetworkProfiler()),
+		}
+
+		_, err := client.Get("http://" + address + "/users?token=secret")
+		s.Error(err)
+
+		logRecord := s.buffer.String()
+		s.Contains(logRecord, `"error_class":"connect"`)
+		s.Contains(logRecord, "connection refused")
+		s.Contains(logRecord, `"elapsed"`)
+		s.Contains(logRecord, `"network"`)
+		s.Contains(logRecord, `"level":"err"`)
+		s.NotContains(logRecord, "secret")
+	})
+
+	s.T().Run("Timeout", func(t *testing.T) {
+		defer s.buffer.Reset()
+
+		client := &http.Client{
+			Transport: WithMiddleware(nil, NewResponseLogger(s.logger)),
+			Timeout:   10 * time.Millisecond,
+		}
+
+		_, err := client.Get(slow.URL)
+		s.Error(err)
+		s.Contains(s.buffer.String(), `"error_class":"timeout"`)
+	})
+}
+
+func (s *ResponseLoggerSuite) Test_NetworkDNS() {
+	defer s.buffer.Reset()
+
+	request, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	request = profile.Observe(request)
+
+	start := time.Now()
+	report := profile.ReportFromContext(request.Context())
+	report.DNSLookupStart = start
+	report.DNSLookupDone = start.Add(3 * time.Millisecond)
+	report.ConnectStart = start.Add(3 * time.Millisecond)
+	report.ConnectDone = start.Add(10 * time.Millisecond)
+
+	response := &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("")), Request: request}
+	s.NoError(NewResponseLogger(s.logger).Process(response))
+
+	var record struct {
+		Content struct {
+			Network map[string]interface{} `json:"network"`
+		} `json:"content"`
+	}
+	s.Require().NoError(json.Unmarshal(s.buffer.Bytes(), &record))
+	s.Equal(float64(3), record.Content.Network["dns"], "DNS lookup time, not the connection time")
+	s.Equal(float64(7), record.Content.Network["connection"])
+}
+
 func (s *ResponseLoggerSuite) LogNotEmpty() bool {
 	return s.NotEmpty(s.buffer.String())
 }
@@ -17,7 +17,7 @@ func TestResponseEntry(t *testing.T) {
 	request.Header.Set("Test", "1")
 	client := &http.Client{}
 	response, _ := client.Do(request)
-	responseEntry, err := newResponseEntry(response)
+	responseEntry, err := newResponseEntry(response, nil, nil)
 
 	if responseEntry.StatusCode != 200 {
 		t.Error("expect status code to be 200")
@@ -0,0 +1,97 @@
+package sigv4
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	chunkAlgorithm       = "AWS4-HMAC-SHA256-PAYLOAD"
+	chunkSignaturePrefix = ";chunk-signature="
+	emptySHA256          = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// hex signature length + 2x CRLF
+	chunkOverhead = len(chunkSignaturePrefix) + 64 + 4
+)
+
+// chunkedReader encodes the body as "aws-chunked", each chunk is signed with the previous signature
+type chunkedReader struct {
+	body  io.ReadCloser
+	chunk []byte
+
+	key       []byte
+	amzDate   string
+	scope     string
+	signature string
+
+	buf  bytes.Buffer
+	done bool
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	return r.buf.Read(p)
+}
+
+func (r *chunkedReader) Close() error {
+	return r.body.Close()
+}
+
+func (r *chunkedReader) nextChunk() error {
+	n, err := io.ReadFull(r.body, r.chunk)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	data := r.chunk[:n]
+	r.signature = r.chunkSignature(data)
+
+	r.buf.WriteString(strconv.FormatInt(int64(n), 16))
+	r.buf.WriteString(chunkSignaturePrefix)
+	r.buf.WriteString(r.signature)
+	r.buf.WriteString("\r\n")
+	r.buf.Write(data)
+	r.buf.WriteString("\r\n")
+
+	// The final chunk has zero length
+	r.done = n == 0
+
+	return nil
+}
+
+func (r *chunkedReader) chunkSignature(data []byte) string {
+	stringToSign := strings.Join([]string{
+		chunkAlgorithm,
+		r.amzDate,
+		r.scope,
+		r.signature,
+		emptySHA256,
+		hashHex(data),
+	}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(r.key, stringToSign))
+}
+
+// streamingContentLength length of the "aws-chunked" encoded body
+func streamingContentLength(length, chunkSize int64) int64 {
+	chunkLength := func(size int64) int64 {
+		return int64(len(strconv.FormatInt(size, 16))+chunkOverhead) + size
+	}
+
+	total := (length / chunkSize) * chunkLength(chunkSize)
+	if rest := length % chunkSize; rest > 0 {
+		total += chunkLength(rest)
+	}
+
+	return total + chunkLength(0)
+}
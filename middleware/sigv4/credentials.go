@@ -0,0 +1,82 @@
+package sigv4
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// Credentials AWS access key
+	Credentials struct {
+		AccessKeyID     string
+		SecretAccessKey string
+		SessionToken    string
+		// Expires zero for non-expiring credentials
+		Expires time.Time
+	}
+
+	// CredentialsProvider retrieves credentials for each signed request
+	CredentialsProvider interface {
+		Retrieve(ctx context.Context) (Credentials, error)
+	}
+
+	// CredentialsProviderFn callback as a CredentialsProvider
+	CredentialsProviderFn func(ctx context.Context) (Credentials, error)
+
+	staticCredentials Credentials
+
+	// RefreshingCredentials caches credentials of the provider until shortly before they expire
+	RefreshingCredentials struct {
+		provider CredentialsProvider
+		window   time.Duration
+
+		mu          sync.Mutex
+		credentials Credentials
+	}
+)
+
+// Retrieve calls the callback
+func (fn CredentialsProviderFn) Retrieve(ctx context.Context) (Credentials, error) {
+	return fn(ctx)
+}
+
+// StaticCredentials never expiring credentials
+func StaticCredentials(accessKeyID, secretAccessKey, sessionToken string) CredentialsProvider {
+	return staticCredentials{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    sessionToken,
+	}
+}
+
+func (c staticCredentials) Retrieve(context.Context) (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// NewRefreshingCredentials calls the provider again when the cached credentials expire within the window
+func NewRefreshingCredentials(provider CredentialsProvider, window time.Duration) *RefreshingCredentials {
+	return &RefreshingCredentials{
+		provider: provider,
+		window:   window,
+	}
+}
+
+// Retrieve returns cached credentials or retrieves new ones
+func (r *RefreshingCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.credentials.AccessKeyID != "" &&
+		(r.credentials.Expires.IsZero() || time.Now().Add(r.window).Before(r.credentials.Expires)) {
+		return r.credentials, nil
+	}
+
+	credentials, err := r.provider.Retrieve(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	r.credentials = credentials
+
+	return credentials, nil
+}
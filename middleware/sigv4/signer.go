@@ -0,0 +1,327 @@
+package sigv4
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/best-expendables/httpclient/middleware"
+)
+
+const (
+	algorithm        = "AWS4-HMAC-SHA256"
+	timeFormat       = "20060102T150405Z"
+	shortTimeFormat  = "20060102"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+	// DefaultChunkSize chunk size of the streaming payload
+	DefaultChunkSize = 64 * 1024
+)
+
+// PayloadMode how the request body is signed
+type PayloadMode int
+
+const (
+	// PayloadSigned body is read into memory and its SHA256 is signed
+	PayloadSigned PayloadMode = iota
+	// PayloadUnsigned body is not signed ("UNSIGNED-PAYLOAD"), e.g. for S3 uploads over HTTPS
+	PayloadUnsigned
+	// PayloadStreaming body is sent and signed by chunks ("aws-chunked" content encoding), request.ContentLength is required.
+	// Requests without body are signed as PayloadSigned.
+	PayloadStreaming
+)
+
+// ErrUnknownContentLength streaming payload requires the content length
+var ErrUnknownContentLength = errors.New("sigv4: streaming payload requires content length")
+
+// headers which can be changed by proxies or the transport
+var ignoredHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+	"connection":      true,
+}
+
+// Signer signs requests with AWS Signature Version 4
+type Signer struct {
+	service     string
+	region      string
+	credentials CredentialsProvider
+	payload     PayloadMode
+	chunkSize   int
+	now         func() time.Time
+}
+
+// NewSigner creates a signer and middleware, e.g. NewSigner("s3", "us-east-1", StaticCredentials(id, secret, ""))
+func NewSigner(service, region string, credentials CredentialsProvider) *Signer {
+	return &Signer{
+		service:     service,
+		region:      region,
+		credentials: credentials,
+		chunkSize:   DefaultChunkSize,
+		now:         time.Now,
+	}
+}
+
+// WithPayloadMode sets how the request body is signed
+func (s *Signer) WithPayloadMode(mode PayloadMode) *Signer {
+	s.payload = mode
+	return s
+}
+
+// WithChunkSize sets chunk size of the streaming payload
+func (s *Signer) WithChunkSize(size int) *Signer {
+	s.chunkSize = size
+	return s
+}
+
+// RoundTripper middleware function
+func (s *Signer) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFn(func(request *http.Request) (*http.Response, error) {
+		signed, err := s.Sign(request, s.now())
+		if err != nil {
+			return nil, err
+		}
+
+		return next.RoundTrip(signed)
+	})
+}
+
+// Sign returns a signed copy of the request
+func (s *Signer) Sign(request *http.Request, signTime time.Time) (*http.Request, error) {
+	credentials, err := s.credentials.Retrieve(request.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	signTime = signTime.UTC()
+	amzDate := signTime.Format(timeFormat)
+	scope := strings.Join([]string{signTime.Format(shortTimeFormat), s.region, s.service, "aws4_request"}, "/")
+
+	request = middleware.CloneRequest(request)
+	request.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	payload := s.payload
+	if payload == PayloadStreaming && (request.Body == nil || request.Body == http.NoBody) {
+		// Nothing to stream, the empty payload is signed
+		payload = PayloadSigned
+	}
+
+	var payloadHash string
+	switch payload {
+	case PayloadUnsigned:
+		payloadHash = unsignedPayload
+		request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	case PayloadStreaming:
+		if request.ContentLength < 0 || (request.ContentLength == 0 && request.Body != nil && request.Body != http.NoBody) {
+			return nil, ErrUnknownContentLength
+		}
+		payloadHash = streamingPayload
+		request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		request.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprint(request.ContentLength))
+		request.Header.Set("Content-Encoding", appendEncoding(request.Header.Get("Content-Encoding")))
+		request.ContentLength = streamingContentLength(request.ContentLength, int64(s.chunkSize))
+	default:
+		body, err := readBody(request)
+		if err != nil {
+			return nil, err
+		}
+		payloadHash = hashHex(body)
+		// S3 requires the payload hash header
+		if s.service == "s3" {
+			request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		}
+	}
+
+	headers, signedHeaders := s.canonicalHeaders(request, payload)
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		s.canonicalURI(request.URL),
+		canonicalQuery(request.URL),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{algorithm, amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+	key := signingKey(credentials.SecretAccessKey, signTime.Format(shortTimeFormat), s.region, s.service)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, credentials.AccessKeyID, scope, signedHeaders, signature))
+
+	if payload == PayloadStreaming {
+		// Chunk signatures can not be replayed from the original body
+		request.GetBody = nil
+		request.Body = &chunkedReader{
+			body:      request.Body,
+			chunk:     make([]byte, s.chunkSize),
+			key:       key,
+			amzDate:   amzDate,
+			scope:     scope,
+			signature: signature,
+		}
+	}
+
+	return request, nil
+}
+
+// canonicalURI path is normalized and URI-encoded twice, except S3 (encoded once, not normalized)
+func (s *Signer) canonicalURI(u *url.URL) string {
+	if s.service == "s3" {
+		uri := uriEncode(u.Path, false)
+		if uri == "" {
+			return "/"
+		}
+		return uri
+	}
+
+	uri := u.EscapedPath()
+	if uri == "" {
+		return "/"
+	}
+	clean := path.Clean(uri)
+	if strings.HasSuffix(uri, "/") && clean != "/" {
+		clean += "/"
+	}
+
+	return uriEncode(clean, false)
+}
+
+func (s *Signer) canonicalHeaders(request *http.Request, payload PayloadMode) (string, string) {
+	values := map[string][]string{}
+	for name, v := range request.Header {
+		name = strings.ToLower(name)
+		if !ignoredHeaders[name] {
+			values[name] = append(values[name], v...)
+		}
+	}
+
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+	values["host"] = []string{host}
+	if payload == PayloadStreaming {
+		values["content-length"] = []string{fmt.Sprint(request.ContentLength)}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := bytes.Buffer{}
+	for _, name := range names {
+		trimmed := make([]string, 0, len(values[name]))
+		for _, value := range values[name] {
+			trimmed = append(trimmed, strings.Join(strings.Fields(value), " "))
+		}
+		buf.WriteString(name)
+		buf.WriteByte(':')
+		buf.WriteString(strings.Join(trimmed, ","))
+		buf.WriteByte('\n')
+	}
+
+	return buf.String(), strings.Join(names, ";")
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+
+	// Sorted by encoded key, then by encoded value
+	pairs := make([][2]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, [2]string{uriEncode(key, true), uriEncode(value, true)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	encoded := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		encoded = append(encoded, pair[0]+"="+pair[1])
+	}
+
+	return strings.Join(encoded, "&")
+}
+
+// uriEncode encodes everything except unreserved characters (RFC 3986), "/" is kept unless encodeSlash
+func uriEncode(s string, encodeSlash bool) string {
+	buf := bytes.Buffer{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func appendEncoding(encoding string) string {
+	if encoding == "" {
+		return "aws-chunked"
+	}
+	return "aws-chunked," + encoding
+}
+
+func readBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, err
+}
+
+type roundTripperFn func(r *http.Request) (*http.Response, error)
+
+// RoundTrip
+func (f roundTripperFn) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
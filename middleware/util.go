@@ -23,7 +23,31 @@ func (l *logger) get(ctx context.Context) log.Entry {
 	return l.logger
 }
 
-func cloneRequest(r *http.Request) *http.Request {
+// logWithLevel writes the record with the level
+func logWithLevel(entry log.Entry, level log.Level, args ...interface{}) {
+	switch level {
+	case log.EmergencyLevel:
+		entry.Emergency(args...)
+	case log.AlertLevel:
+		entry.Alert(args...)
+	case log.CriticalLevel:
+		entry.Critical(args...)
+	case log.ErrorLevel:
+		entry.Error(args...)
+	case log.WarningLevel:
+		entry.Warning(args...)
+	case log.NoticeLevel:
+		entry.Notice(args...)
+	case log.DebugLevel:
+		entry.Debug(args...)
+	default:
+		entry.Info(args...)
+	}
+}
+
+// CloneRequest shallow copy of the request with a copy of the header,
+// middlewares modify the clone instead of the request of the caller
+func CloneRequest(r *http.Request) *http.Request {
 	r2 := new(http.Request)
 	*r2 = *r
 	// deep copy of the Header
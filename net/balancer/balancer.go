@@ -0,0 +1,332 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outlier detection defaults
+const (
+	DefaultConsecutiveFailures = 5
+	DefaultBaseEjectionTime    = 10 * time.Second
+	DefaultMaxEjectionTime     = 5 * time.Minute
+)
+
+// ErrNoEndpoints balancer has been created without endpoints
+var ErrNoEndpoints = errors.New("balancer: no endpoints")
+
+type ctxKey int
+
+const endpointCtxKey ctxKey = iota
+
+type (
+	// Target endpoint configuration, Weight is used by WeightedRoundRobin (1 by default)
+	Target struct {
+		URL    string `json:"url" yaml:"url"`
+		Weight int    `json:"weight,omitempty" yaml:"weight,omitempty"`
+	}
+
+	// Endpoint backend with its balancing state
+	Endpoint struct {
+		rawURL string
+		url    *url.URL
+		weight int
+
+		inFlight      int
+		currentWeight int
+		failures      int
+		ejections     int
+		ejectedUntil  time.Time
+	}
+
+	// FailureFn decides if the request to the endpoint has failed
+	FailureFn func(response *http.Response, err error) bool
+
+	// Balancer spreads requests across endpoints and ejects endpoints
+	// after consecutive failures for exponentially growing time (outlier detection).
+	// If all endpoints are ejected then all of them are used.
+	Balancer struct {
+		strategy            Strategy
+		failureFn           FailureFn
+		consecutiveFailures int
+		baseEjectionTime    time.Duration
+		maxEjectionTime     time.Duration
+
+		mu        sync.Mutex
+		endpoints []*Endpoint
+		// reusable slice of available endpoints
+		available []*Endpoint
+	}
+)
+
+// Targets endpoints with the same weight
+func Targets(urls ...string) []Target {
+	targets := make([]Target, 0, len(urls))
+	for _, u := range urls {
+		targets = append(targets, Target{URL: u})
+	}
+	return targets
+}
+
+// New creates balancer, the outlier detection is enabled with default settings
+func New(strategy Strategy, targets ...Target) (*Balancer, error) {
+	if len(targets) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	b := &Balancer{
+		strategy:            strategy,
+		failureFn:           IsFailure,
+		consecutiveFailures: DefaultConsecutiveFailures,
+		baseEjectionTime:    DefaultBaseEjectionTime,
+		maxEjectionTime:     DefaultMaxEjectionTime,
+	}
+
+	if err := b.SetTargets(targets...); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// SetTargets replaces endpoints at runtime (e.g. by service discovery).
+// State of the endpoints with the same URL (in-flight requests, ejection) is kept.
+// The endpoints are not changed on error.
+func (b *Balancer) SetTargets(targets ...Target) error {
+	if len(targets) == 0 {
+		return ErrNoEndpoints
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	existing := make(map[string]*Endpoint, len(b.endpoints))
+	for _, e := range b.endpoints {
+		existing[e.rawURL] = e
+	}
+
+	endpoints := make([]*Endpoint, 0, len(targets))
+	for _, target := range targets {
+		endpoint, err := newEndpoint(target)
+		if err != nil {
+			return err
+		}
+		if e, ok := existing[target.URL]; ok {
+			e.weight = endpoint.weight
+			endpoint = e
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	b.endpoints = endpoints
+
+	return nil
+}
+
+// Targets current endpoints
+func (b *Balancer) Targets() []Target {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	targets := make([]Target, 0, len(b.endpoints))
+	for _, e := range b.endpoints {
+		targets = append(targets, Target{URL: e.rawURL, Weight: e.weight})
+	}
+	return targets
+}
+
+// WithOutlierDetection endpoint is ejected after consecutive failures for baseEjectionTime,
+// the time is doubled for every next ejection up to maxEjectionTime. Zero consecutiveFailures disables the detection.
+func (b *Balancer) WithOutlierDetection(consecutiveFailures int, baseEjectionTime, maxEjectionTime time.Duration) *Balancer {
+	b.consecutiveFailures = consecutiveFailures
+	b.baseEjectionTime = baseEjectionTime
+	b.maxEjectionTime = maxEjectionTime
+	return b
+}
+
+// WithFailureFn custom failure detection, IsFailure by default
+func (b *Balancer) WithFailureFn(fn FailureFn) *Balancer {
+	b.failureFn = fn
+	return b
+}
+
+// Pick returns endpoint for the next request, Done must be called when the request is finished
+func (b *Balancer) Pick() *Endpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.available = b.available[:0]
+	for _, e := range b.endpoints {
+		if !now.Before(e.ejectedUntil) {
+			b.available = append(b.available, e)
+		}
+	}
+	if len(b.available) == 0 {
+		b.available = append(b.available, b.endpoints...)
+	}
+
+	e := b.strategy.Pick(b.available)
+	e.inFlight++
+
+	return e
+}
+
+// Done releases the endpoint and updates outlier detection
+func (b *Balancer) Done(e *Endpoint, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e.inFlight--
+
+	if !failed {
+		e.failures = 0
+		e.ejections = 0
+		return
+	}
+
+	e.failures++
+	if b.consecutiveFailures <= 0 || e.failures < b.consecutiveFailures {
+		return
+	}
+
+	ejectionTime := b.baseEjectionTime << uint(e.ejections)
+	if ejectionTime > b.maxEjectionTime || ejectionTime <= 0 {
+		ejectionTime = b.maxEjectionTime
+	}
+	e.ejectedUntil = time.Now().Add(ejectionTime)
+	e.ejections++
+	e.failures = 0
+}
+
+// release releases the endpoint without updating outlier detection
+func (b *Balancer) release(e *Endpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e.inFlight--
+}
+
+// Ejected URLs of currently ejected endpoints
+func (b *Balancer) Ejected() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var ejected []string
+	now := time.Now()
+	for _, e := range b.endpoints {
+		if now.Before(e.ejectedUntil) {
+			ejected = append(ejected, e.rawURL)
+		}
+	}
+	return ejected
+}
+
+// RoundTripper middleware sends the request to the picked endpoint:
+// scheme and host of the request URL are replaced and the endpoint path is used as a prefix.
+// The endpoint is available from the response, see EndpointFromResponse
+func (b *Balancer) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFn(func(r *http.Request) (*http.Response, error) {
+		e := b.Pick()
+
+		ctx := context.WithValue(r.Context(), endpointCtxKey, e)
+		r = r.WithContext(ctx)
+		r.URL = e.resolve(r.URL)
+		r.Host = ""
+
+		response, err := next.RoundTrip(r)
+		if err != nil && ctx.Err() != nil {
+			// Canceled or timed out by the caller, or abandoned (e.g. the losing hedging attempt),
+			// says nothing about the endpoint
+			b.release(e)
+		} else {
+			b.Done(e, b.failureFn(response, err))
+		}
+
+		return response, err
+	})
+}
+
+// IsFailure transport errors and 5xx responses.
+// Errors of the requests canceled by the caller (context error) are not passed to FailureFn.
+func IsFailure(response *http.Response, err error) bool {
+	return err != nil || response.StatusCode >= http.StatusInternalServerError
+}
+
+// EndpointFromContext endpoint which serves the request
+func EndpointFromContext(ctx context.Context) *Endpoint {
+	if e, ok := ctx.Value(endpointCtxKey).(*Endpoint); ok {
+		return e
+	}
+	return nil
+}
+
+// EndpointFromResponse endpoint which has served the request
+func EndpointFromResponse(response *http.Response) *Endpoint {
+	if response == nil || response.Request == nil {
+		return nil
+	}
+	return EndpointFromContext(response.Request.Context())
+}
+
+func newEndpoint(target Target) (*Endpoint, error) {
+	u, err := url.Parse(strings.TrimRight(target.URL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("balancer: endpoint %q must be absolute URL", target.URL)
+	}
+
+	weight := target.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+
+	return &Endpoint{
+		rawURL: target.URL,
+		url:    u,
+		weight: weight,
+	}, nil
+}
+
+// URL of the endpoint as configured
+func (e *Endpoint) URL() string {
+	return e.rawURL
+}
+
+// Weight of the endpoint
+func (e *Endpoint) Weight() int {
+	return e.weight
+}
+
+// InFlight number of requests in flight, must be used only by Strategy
+func (e *Endpoint) InFlight() int {
+	return e.inFlight
+}
+
+// resolve URL of the endpoint for the request URL
+func (e *Endpoint) resolve(u *url.URL) *url.URL {
+	resolved := *u
+	resolved.Scheme = e.url.Scheme
+	resolved.Host = e.url.Host
+	resolved.User = e.url.User
+	resolved.Path = e.url.Path + "/" + strings.TrimLeft(u.Path, "/")
+	resolved.RawPath = ""
+	if u.RawPath != "" {
+		resolved.RawPath = e.url.EscapedPath() + "/" + strings.TrimLeft(u.RawPath, "/")
+	}
+
+	return &resolved
+}
+
+type roundTripperFn func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFn) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,207 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/best-expendables/httpclient/net"
+	"github.com/stretchr/testify/suite"
+)
+
+type BalancerSuite struct {
+	suite.Suite
+}
+
+func (s *BalancerSuite) TestNew() {
+	_, err := New(RoundRobin())
+	s.Equal(ErrNoEndpoints, err)
+
+	_, err = New(RoundRobin(), Targets("localhost:8080")...)
+	s.Error(err)
+
+	b, err := New(WeightedRoundRobin(), Target{URL: "http://a"}, Target{URL: "http://b", Weight: 3})
+	s.Require().NoError(err)
+	s.Equal(1, b.endpoints[0].Weight())
+	s.Equal(3, b.endpoints[1].Weight())
+}
+
+func (s *BalancerSuite) TestOutlierDetection() {
+	b, _ := New(RoundRobin(), Targets("http://a", "http://b")...)
+	b.WithOutlierDetection(2, 20*time.Millisecond, 30*time.Millisecond)
+
+	a := b.endpoints[0]
+	fail := func() {
+		a.inFlight++
+		b.Done(a, true)
+	}
+
+	fail()
+	s.Empty(b.Ejected())
+	fail()
+	s.Equal([]string{"http://a"}, b.Ejected())
+	s.Equal(0, a.InFlight())
+
+	for i := 0; i < 4; i++ {
+		e := b.Pick()
+		s.Equal("http://b", e.URL())
+		b.Done(e, false)
+	}
+
+	// Re-admitted after the ejection time, the next ejection is longer (capped by max)
+	time.Sleep(25 * time.Millisecond)
+	s.Empty(b.Ejected())
+	fail()
+	fail()
+	s.Equal([]string{"http://a"}, b.Ejected())
+	s.WithinDuration(time.Now().Add(30*time.Millisecond), a.ejectedUntil, 5*time.Millisecond)
+
+	// Success resets the exponential ejection
+	a.inFlight++
+	b.Done(a, false)
+	s.Equal(0, a.ejections)
+}
+
+func (s *BalancerSuite) TestAllEjected() {
+	b, _ := New(RoundRobin(), Targets("http://a", "http://b")...)
+	b.WithOutlierDetection(1, time.Minute, time.Minute)
+
+	b.Done(b.Pick(), true)
+	b.Done(b.Pick(), true)
+	s.Len(b.Ejected(), 2)
+
+	s.NotNil(b.Pick())
+}
+
+func (s *BalancerSuite) TestDisabledOutlierDetection() {
+	b, _ := New(RoundRobin(), Targets("http://a")...)
+	b.WithOutlierDetection(0, time.Minute, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		b.Done(b.Pick(), true)
+	}
+	s.Empty(b.Ejected())
+}
+
+func (s *BalancerSuite) TestRoundTripper() {
+	var paths []string
+	newServer := func(status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.Host+r.URL.RequestURI())
+			w.WriteHeader(status)
+		}))
+	}
+	healthy := newServer(http.StatusOK)
+	defer healthy.Close()
+	broken := newServer(http.StatusServiceUnavailable)
+	defer broken.Close()
+
+	b, _ := New(RoundRobin(), Targets(healthy.URL+"/api/", broken.URL)...)
+	b.WithOutlierDetection(1, time.Minute, time.Minute)
+	client := &http.Client{Transport: b.RoundTripper(http.DefaultTransport)}
+
+	served := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		response, err := client.Get("http://service/users?id=1")
+		s.Require().NoError(err)
+		response.Body.Close()
+		served[EndpointFromResponse(response).URL()]++
+	}
+
+	s.Equal(map[string]int{healthy.URL + "/api/": 3, broken.URL: 1}, served)
+	s.Equal([]string{broken.URL}, b.Ejected())
+	s.Contains(paths, healthy.Listener.Addr().String()+"/api/users?id=1")
+	s.Contains(paths, broken.Listener.Addr().String()+"/users?id=1")
+
+	s.Nil(EndpointFromResponse(nil))
+}
+
+func (s *BalancerSuite) TestRoundTripper_Error() {
+	b, _ := New(RoundRobin(), Targets("http://a")...)
+	b.WithOutlierDetection(1, time.Minute, time.Minute)
+
+	rt := b.RoundTripper(roundTripperFn(func(r *http.Request) (*http.Response, error) {
+		s.Equal("a", r.URL.Host)
+		return nil, errors.New("connection refused")
+	}))
+
+	request, _ := http.NewRequest(http.MethodGet, "/path", nil)
+	_, err := rt.RoundTrip(request)
+	s.Error(err)
+	s.Equal([]string{"http://a"}, b.Ejected())
+}
+
+func (s *BalancerSuite) TestRoundTripper_Canceled() {
+	b, _ := New(RoundRobin(), Targets("http://a")...)
+	b.WithOutlierDetection(2, time.Minute, time.Minute)
+
+	var failure error
+	rt := b.RoundTripper(roundTripperFn(func(r *http.Request) (*http.Response, error) {
+		if failure != nil {
+			return nil, failure
+		}
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	}))
+	request, _ := http.NewRequest(http.MethodGet, "/path", nil)
+
+	failure = errors.New("connection refused")
+	rt.RoundTrip(request)
+	s.Equal(1, b.endpoints[0].failures)
+
+	failure = nil
+	for _, done := range []func(ctx context.Context) context.Context{
+		func(ctx context.Context) context.Context {
+			ctx, cancel := context.WithCancel(ctx)
+			cancel()
+			return ctx
+		},
+		func(ctx context.Context) context.Context {
+			ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+			defer cancel()
+			<-ctx.Done()
+			return ctx
+		},
+		func(ctx context.Context) context.Context {
+			ctx, cancel := context.WithCancel(ctx)
+			ctx, abandon := net.ContextWithAbandon(ctx)
+			abandon()
+			cancel()
+			return ctx
+		},
+	} {
+		_, err := rt.RoundTrip(request.WithContext(done(context.Background())))
+		s.Error(err)
+	}
+
+	s.Equal(1, b.endpoints[0].failures, "canceled requests do not change the failures")
+	s.Empty(b.Ejected())
+	s.Equal(0, b.endpoints[0].InFlight())
+}
+
+func (s *BalancerSuite) TestSetTargets() {
+	b, _ := New(RoundRobin(), Targets("http://a", "http://b")...)
+	b.WithOutlierDetection(1, time.Minute, time.Minute)
+
+	a := b.endpoints[0]
+	a.inFlight++
+	b.Done(a, true)
+	s.Equal([]string{"http://a"}, b.Ejected())
+
+	s.NoError(b.SetTargets(Target{URL: "http://a", Weight: 2}, Target{URL: "http://c"}))
+	s.Equal([]Target{{URL: "http://a", Weight: 2}, {URL: "http://c", Weight: 1}}, b.Targets())
+	// State of the kept endpoint
+	s.Equal([]string{"http://a"}, b.Ejected())
+	s.Equal(2, a.Weight())
+
+	s.Equal(ErrNoEndpoints, b.SetTargets())
+	s.Error(b.SetTargets(Target{URL: "invalid"}))
+	s.Len(b.Targets(), 2)
+}
+
+func TestBalancerRunner(t *testing.T) {
+	suite.Run(t, new(BalancerSuite))
+}
@@ -0,0 +1,102 @@
+package balancer
+
+import (
+	"math/rand"
+	"time"
+)
+
+type (
+	// Strategy picks one of the available endpoints.
+	// It is called under the balancer lock, so implementations do not need synchronization.
+	Strategy interface {
+		Pick(endpoints []*Endpoint) *Endpoint
+	}
+
+	roundRobin struct {
+		next int
+	}
+
+	weightedRoundRobin struct{}
+
+	leastInFlight struct {
+		next int
+	}
+
+	powerOfTwoChoices struct {
+		rand *rand.Rand
+	}
+)
+
+// RoundRobin picks endpoints in turn
+func RoundRobin() Strategy {
+	return &roundRobin{}
+}
+
+func (s *roundRobin) Pick(endpoints []*Endpoint) *Endpoint {
+	s.next++
+	return endpoints[s.next%len(endpoints)]
+}
+
+// WeightedRoundRobin smooth weighted round-robin (as in nginx), endpoints with higher Weight get proportionally more requests
+func WeightedRoundRobin() Strategy {
+	return weightedRoundRobin{}
+}
+
+func (weightedRoundRobin) Pick(endpoints []*Endpoint) *Endpoint {
+	var (
+		best  *Endpoint
+		total int
+	)
+	for _, e := range endpoints {
+		e.currentWeight += e.Weight()
+		total += e.Weight()
+		if best == nil || e.currentWeight > best.currentWeight {
+			best = e
+		}
+	}
+	best.currentWeight -= total
+
+	return best
+}
+
+// LeastInFlight picks endpoint with the least number of requests in flight, ties are resolved in turn
+func LeastInFlight() Strategy {
+	return &leastInFlight{}
+}
+
+func (s *leastInFlight) Pick(endpoints []*Endpoint) *Endpoint {
+	s.next++
+
+	var best *Endpoint
+	for i := range endpoints {
+		e := endpoints[(s.next+i)%len(endpoints)]
+		if best == nil || e.InFlight() < best.InFlight() {
+			best = e
+		}
+	}
+	return best
+}
+
+// PowerOfTwoChoices picks two random endpoints and uses the one with less requests in flight
+func PowerOfTwoChoices() Strategy {
+	return &powerOfTwoChoices{
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *powerOfTwoChoices) Pick(endpoints []*Endpoint) *Endpoint {
+	if len(endpoints) == 1 {
+		return endpoints[0]
+	}
+
+	i := s.rand.Intn(len(endpoints))
+	j := s.rand.Intn(len(endpoints) - 1)
+	if j >= i {
+		j++
+	}
+
+	if endpoints[j].InFlight() < endpoints[i].InFlight() {
+		return endpoints[j]
+	}
+	return endpoints[i]
+}
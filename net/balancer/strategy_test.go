@@ -0,0 +1,67 @@
+package balancer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func endpoints(weights ...int) []*Endpoint {
+	result := make([]*Endpoint, 0, len(weights))
+	for i, weight := range weights {
+		result = append(result, &Endpoint{rawURL: string(rune('a' + i)), weight: weight})
+	}
+	return result
+}
+
+func pickCounts(strategy Strategy, list []*Endpoint, n int) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[strategy.Pick(list).URL()]++
+	}
+	return counts
+}
+
+func TestRoundRobin(t *testing.T) {
+	counts := pickCounts(RoundRobin(), endpoints(1, 1, 1), 9)
+	assert.Equal(t, map[string]int{"a": 3, "b": 3, "c": 3}, counts)
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	list := endpoints(5, 1, 1)
+	strategy := WeightedRoundRobin()
+
+	// Smooth distribution: the heavy endpoint is not picked 5 times in a row
+	sequence := ""
+	for i := 0; i < 7; i++ {
+		sequence += strategy.Pick(list).URL()
+	}
+	assert.Equal(t, "aabacaa", sequence)
+
+	assert.Equal(t, map[string]int{"a": 50, "b": 10, "c": 10}, pickCounts(strategy, list, 70))
+}
+
+func TestLeastInFlight(t *testing.T) {
+	list := endpoints(1, 1, 1)
+	list[0].inFlight = 2
+	list[1].inFlight = 1
+	list[2].inFlight = 3
+
+	assert.Equal(t, "b", LeastInFlight().Pick(list).URL())
+
+	list[1].inFlight = 2
+	list[2].inFlight = 2
+	assert.Equal(t, map[string]int{"a": 3, "b": 3, "c": 3}, pickCounts(LeastInFlight(), list, 9))
+}
+
+func TestPowerOfTwoChoices(t *testing.T) {
+	list := endpoints(1, 1, 1)
+	list[0].inFlight = 10
+	list[1].inFlight = 10
+
+	counts := pickCounts(PowerOfTwoChoices(), list, 300)
+	// "c" wins every time when it is one of the choices, i.e. 2 of 3 pairs
+	assert.InDelta(t, 200, counts["c"], 40)
+
+	assert.Equal(t, "a", PowerOfTwoChoices().Pick(list[:1]).URL())
+}
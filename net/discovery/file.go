@@ -0,0 +1,74 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/best-expendables/httpclient/net/balancer"
+	"gopkg.in/yaml.v2"
+)
+
+// File resolves endpoints from JSON or YAML file (by extension), the file is parsed again when it is modified.
+//
+// E.g. endpoints.json:
+//
+//	[{"url": "http://10.0.0.1:8080", "weight": 2}, {"url": "http://10.0.0.2:8080"}]
+type File struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	targets []balancer.Target
+}
+
+// NewFile resolver for the file, ".json", ".yaml" and ".yml" extensions are supported
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Resolve(context.Context) ([]balancer.Target, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.targets != nil && info.ModTime().Equal(f.modTime) {
+		return f.targets, nil
+	}
+
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []balancer.Target
+	switch ext := strings.ToLower(filepath.Ext(f.path)); ext {
+	case ".json":
+		err = json.Unmarshal(content, &targets)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &targets)
+	default:
+		err = fmt.Errorf("discovery: unsupported file format %q", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, balancer.ErrNoEndpoints
+	}
+
+	f.targets = targets
+	f.modTime = info.ModTime()
+
+	return targets, nil
+}
@@ -0,0 +1,76 @@
+package discovery
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/best-expendables/httpclient/net/balancer"
+	"github.com/stretchr/testify/suite"
+)
+
+type FileSuite struct {
+	suite.Suite
+
+	dir string
+}
+
+func (s *FileSuite) SetupTest() {
+	var err error
+	s.dir, err = ioutil.TempDir("", "discovery")
+	s.Require().NoError(err)
+}
+
+func (s *FileSuite) TearDownTest() {
+	os.RemoveAll(s.dir)
+}
+
+func (s *FileSuite) TestJSON() {
+	path := s.write("endpoints.json", `[{"url": "http://a", "weight": 2}, {"url": "http://b"}]`, time.Now())
+
+	targets, err := NewFile(path).Resolve(context.Background())
+	s.NoError(err)
+	s.Equal([]balancer.Target{{URL: "http://a", Weight: 2}, {URL: "http://b"}}, targets)
+}
+
+func (s *FileSuite) TestYAMLReload() {
+	path := s.write("endpoints.yaml", "- url: http://a\n  weight: 2\n", time.Now())
+	file := NewFile(path)
+
+	targets, err := file.Resolve(context.Background())
+	s.NoError(err)
+	s.Equal([]balancer.Target{{URL: "http://a", Weight: 2}}, targets)
+
+	s.write("endpoints.yaml", "- url: http://b\n- url: http://c\n", time.Now().Add(time.Minute))
+	targets, err = file.Resolve(context.Background())
+	s.NoError(err)
+	s.Equal(balancer.Targets("http://b", "http://c"), targets)
+}
+
+func (s *FileSuite) TestInvalid() {
+	_, err := NewFile(filepath.Join(s.dir, "missing.json")).Resolve(context.Background())
+	s.Error(err)
+
+	_, err = NewFile(s.write("endpoints.txt", "http://a", time.Now())).Resolve(context.Background())
+	s.Error(err)
+
+	_, err = NewFile(s.write("broken.json", "[", time.Now())).Resolve(context.Background())
+	s.Error(err)
+
+	_, err = NewFile(s.write("empty.yml", "[]", time.Now())).Resolve(context.Background())
+	s.Equal(balancer.ErrNoEndpoints, err)
+}
+
+func (s *FileSuite) write(name, content string, modTime time.Time) string {
+	path := filepath.Join(s.dir, name)
+	s.Require().NoError(ioutil.WriteFile(path, []byte(content), 0600))
+	s.Require().NoError(os.Chtimes(path, modTime, modTime))
+	return path
+}
+
+func TestFileRunner(t *testing.T) {
+	suite.Run(t, new(FileSuite))
+}
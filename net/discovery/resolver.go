@@ -0,0 +1,72 @@
+package discovery
+
+import (
+	"context"
+	"time"
+
+	"github.com/best-expendables/httpclient/net/balancer"
+	log "github.com/best-expendables/logger"
+)
+
+type (
+	// Resolver supplies the current endpoint list of a service
+	Resolver interface {
+		Resolve(ctx context.Context) ([]balancer.Target, error)
+	}
+
+	// ResolverFn function as Resolver
+	ResolverFn func(ctx context.Context) ([]balancer.Target, error)
+
+	static []balancer.Target
+)
+
+func (f ResolverFn) Resolve(ctx context.Context) ([]balancer.Target, error) {
+	return f(ctx)
+}
+
+// Static fixed endpoint list
+func Static(targets ...balancer.Target) Resolver {
+	return static(targets)
+}
+
+func (s static) Resolve(context.Context) ([]balancer.Target, error) {
+	return s, nil
+}
+
+// NewBalancer creates balancer with the resolved endpoints
+func NewBalancer(ctx context.Context, resolver Resolver, strategy balancer.Strategy) (*balancer.Balancer, error) {
+	targets, err := resolver.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return balancer.New(strategy, targets...)
+}
+
+// Watch resolves endpoints with the interval and updates the balancer until the context is done.
+// The previous endpoints are kept on resolve errors. It blocks, so run it in a goroutine.
+func Watch(ctx context.Context, resolver Resolver, b *balancer.Balancer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := update(ctx, resolver, b); err != nil {
+				log.EntryFromContextOrDefault(ctx).WithField("source", "Discovery").
+					Warning("Endpoints have not been updated: " + err.Error())
+			}
+		}
+	}
+}
+
+func update(ctx context.Context, resolver Resolver, b *balancer.Balancer) error {
+	targets, err := resolver.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	return b.SetTargets(targets...)
+}
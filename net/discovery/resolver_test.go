@@ -0,0 +1,97 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/best-expendables/httpclient/net/balancer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatic(t *testing.T) {
+	targets, err := Static(balancer.Targets("http://a", "http://b")...).Resolve(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, balancer.Targets("http://a", "http://b"), targets)
+}
+
+func TestNewBalancer(t *testing.T) {
+	b, err := NewBalancer(context.Background(), Static(balancer.Targets("http://a")...), balancer.RoundRobin())
+	assert.NoError(t, err)
+	assert.Equal(t, "http://a", b.Pick().URL())
+
+	_, err = NewBalancer(context.Background(), Static(), balancer.RoundRobin())
+	assert.Equal(t, balancer.ErrNoEndpoints, err)
+}
+
+func TestWatch(t *testing.T) {
+	var calls int32
+	resolver := ResolverFn(func(ctx context.Context) ([]balancer.Target, error) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			return balancer.Targets("http://b"), nil
+		default:
+			return nil, errors.New("resolver is not available")
+		}
+	})
+
+	b, _ := balancer.New(balancer.RoundRobin(), balancer.Targets("http://a")...)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	Watch(ctx, resolver, b, 10*time.Millisecond)
+
+	assert.True(t, atomic.LoadInt32(&calls) > 1)
+	assert.Equal(t, []balancer.Target{{URL: "http://b", Weight: 1}}, b.Targets())
+}
+
+func TestSRV(t *testing.T) {
+	lookup := func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		assert.Equal(t, "http", service)
+		assert.Equal(t, "tcp", proto)
+		assert.Equal(t, "payment.service.consul", name)
+
+		return "_http._tcp.payment.service.consul.", []*net.SRV{
+			{Target: "node1.consul.", Port: 8080, Priority: 10, Weight: 3},
+			{Target: "node2.consul.", Port: 8081, Priority: 10, Weight: 1},
+			{Target: "backup.consul.", Port: 8080, Priority: 20, Weight: 1},
+		}, nil
+	}
+
+	targets, err := NewSRV("http", "tcp", "payment.service.consul").
+		WithScheme("https").
+		WithLookup(lookup).
+		Resolve(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []balancer.Target{
+		{URL: "https://node1.consul:8080", Weight: 3},
+		{URL: "https://node2.consul:8081", Weight: 1},
+	}, targets)
+
+	// Weight 0 record is picked rarely
+	targets, err = NewSRV("http", "tcp", "payment.service.consul").
+		WithLookup(func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			return "", []*net.SRV{
+				{Target: "node1.consul.", Port: 8080, Weight: 2},
+				{Target: "node2.consul.", Port: 8080, Weight: 0},
+			}, nil
+		}).
+		Resolve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []balancer.Target{
+		{URL: "http://node1.consul:8080", Weight: 2 * srvWeightScale},
+		{URL: "http://node2.consul:8080", Weight: 1},
+	}, targets)
+
+	_, err = NewSRV("http", "tcp", "empty").
+		WithLookup(func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			return "", nil, nil
+		}).
+		Resolve(context.Background())
+	assert.Equal(t, balancer.ErrNoEndpoints, err)
+}
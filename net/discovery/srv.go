@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/best-expendables/httpclient/net/balancer"
+)
+
+// srvWeightScale weights are multiplied when records with weight 0 are mixed with weighted ones,
+// the endpoint of the weight 0 record gets 1/srvWeightScale of the traffic of the weight 1 endpoint
+const srvWeightScale = 100
+
+type (
+	// LookupSRVFn see net.Resolver.LookupSRV
+	LookupSRVFn func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+	// SRV resolves endpoints from DNS SRV records (RFC 2782).
+	// Only the records with the lowest priority are used, the record weight is the endpoint weight.
+	// Records with weight 0 are picked rarely if there are weighted records, otherwise equally.
+	SRV struct {
+		service string
+		proto   string
+		name    string
+		scheme  string
+		lookup  LookupSRVFn
+	}
+)
+
+// NewSRV resolver for _service._proto.name, e.g. NewSRV("http", "tcp", "payment.service.consul")
+func NewSRV(service, proto, name string) *SRV {
+	return &SRV{
+		service: service,
+		proto:   proto,
+		name:    name,
+		scheme:  "http",
+		lookup:  net.DefaultResolver.LookupSRV,
+	}
+}
+
+// WithScheme scheme of the endpoint URLs, "http" by default
+func (s *SRV) WithScheme(scheme string) *SRV {
+	s.scheme = scheme
+	return s
+}
+
+// WithLookup custom SRV lookup
+func (s *SRV) WithLookup(lookup LookupSRVFn) *SRV {
+	s.lookup = lookup
+	return s
+}
+
+func (s *SRV) Resolve(ctx context.Context) ([]balancer.Target, error) {
+	_, records, err := s.lookup(ctx, s.service, s.proto, s.name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, balancer.ErrNoEndpoints
+	}
+
+	priority := records[0].Priority
+	for _, record := range records {
+		if record.Priority < priority {
+			priority = record.Priority
+		}
+	}
+
+	var zeroWeight, weighted bool
+	for _, record := range records {
+		if record.Priority == priority {
+			zeroWeight = zeroWeight || record.Weight == 0
+			weighted = weighted || record.Weight > 0
+		}
+	}
+
+	targets := make([]balancer.Target, 0, len(records))
+	for _, record := range records {
+		if record.Priority != priority {
+			continue
+		}
+		// Weight 0 is replaced by 1 in the balancer, so the other weights are scaled (RFC 2782)
+		weight := int(record.Weight)
+		if zeroWeight && weighted {
+			weight *= srvWeightScale
+			if weight == 0 {
+				weight = 1
+			}
+		}
+
+		host := strings.TrimSuffix(record.Target, ".")
+		targets = append(targets, balancer.Target{
+			URL:    s.scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(record.Port))),
+			Weight: weight,
+		})
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,369 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/best-expendables/httpclient/net/profile"
+	"github.com/best-expendables/httpclient/net/transport"
+	log "github.com/best-expendables/logger"
+)
+
+// Defaults, system resolver does not expose record TTLs so DefaultTTL is used unless TTLLookupFn provides them
+const (
+	DefaultTTL           = 30 * time.Second
+	DefaultNegativeTTL   = 5 * time.Second
+	DefaultLookupTimeout = 5 * time.Second
+)
+
+type (
+	// LookupFn resolves host into IP addresses, see net.Resolver.LookupIPAddr
+	LookupFn func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	// TTLLookupFn resolves host into IP addresses with the record TTL (e.g. the minimum TTL of A/AAAA records
+	// from a DNS library), zero TTL falls back to the resolver TTL
+	TTLLookupFn func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error)
+
+	entry struct {
+		addrs   []net.IP
+		err     error
+		expires time.Time
+		// used since the last refresh
+		used bool
+		// ready is closed when the lookup is done
+		ready chan struct{}
+	}
+
+	// Resolver in-memory DNS cache with dialer for the transport.
+	//
+	// E.g.:
+	//	resolver := dnscache.New()
+	//	go resolver.RefreshEvery(ctx, time.Minute)
+	//
+	//	config := transport.DefaultConfig()
+	//	config.DialContext = resolver.DialContext
+	Resolver struct {
+		lookup        TTLLookupFn
+		dialer        *net.Dialer
+		ttl           time.Duration
+		negativeTTL   time.Duration
+		lookupTimeout time.Duration
+		overrides     map[string][]net.IP
+
+		mu      sync.Mutex
+		entries map[string]*entry
+		// round-robin counter
+		next uint32
+	}
+)
+
+// New creates resolver with system DNS resolver and transport dial timeouts
+func New() *Resolver {
+	return &Resolver{
+		lookup: withoutTTL(net.DefaultResolver.LookupIPAddr),
+		dialer: &net.Dialer{
+			Timeout:   transport.DefaultDialTimeout,
+			KeepAlive: transport.DefaultKeepAlive,
+		},
+		ttl:           DefaultTTL,
+		negativeTTL:   DefaultNegativeTTL,
+		lookupTimeout: DefaultLookupTimeout,
+		overrides:     make(map[string][]net.IP),
+		entries:       make(map[string]*entry),
+	}
+}
+
+// WithTTL how long resolved addresses are cached when the lookup does not provide record TTL
+func (r *Resolver) WithTTL(ttl time.Duration) *Resolver {
+	r.ttl = ttl
+	return r
+}
+
+// WithNegativeTTL how long lookup errors are cached
+func (r *Resolver) WithNegativeTTL(ttl time.Duration) *Resolver {
+	r.negativeTTL = ttl
+	return r
+}
+
+// WithLookupTimeout timeout of the DNS lookup, the lookup is shared by concurrent requests
+// and does not depend on their contexts
+func (r *Resolver) WithLookupTimeout(timeout time.Duration) *Resolver {
+	r.lookupTimeout = timeout
+	return r
+}
+
+// WithLookup custom DNS lookup, e.g. (&net.Resolver{PreferGo: true}).LookupIPAddr
+func (r *Resolver) WithLookup(lookup LookupFn) *Resolver {
+	r.lookup = withoutTTL(lookup)
+	return r
+}
+
+// WithTTLLookup custom DNS lookup which provides record TTLs
+func (r *Resolver) WithTTLLookup(lookup TTLLookupFn) *Resolver {
+	r.lookup = lookup
+	return r
+}
+
+// WithDialer dialer for the resolved addresses
+func (r *Resolver) WithDialer(dialer *net.Dialer) *Resolver {
+	r.dialer = dialer
+	return r
+}
+
+// WithOverride static addresses for the host (like /etc/hosts), invalid IP addresses are ignored
+func (r *Resolver) WithOverride(host string, addrs ...string) *Resolver {
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	r.overrides[host] = ips
+
+	return r
+}
+
+// LookupIP returns addresses of the host, cached is false when DNS lookup has been made by this call.
+// The lookup is shared by concurrent calls and runs on its own context (see WithLookupTimeout),
+// canceled context fails only its own call. httptrace DNSStart and DNSDone hooks are called only for the lookup.
+func (r *Resolver) LookupIP(ctx context.Context, host string) (addrs []net.IP, cached bool, err error) {
+	if ips, ok := r.overrides[host]; ok {
+		return ips, true, nil
+	}
+
+	r.mu.Lock()
+	e, ok := r.entries[host]
+	if ok && (!isReady(e) || time.Now().Before(e.expires)) {
+		e.used = true
+		r.mu.Unlock()
+
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return e.addrs, true, e.err
+	}
+
+	previous := e
+	e = &entry{used: true, ready: make(chan struct{})}
+	r.entries[host] = e
+	r.mu.Unlock()
+
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+
+	go r.fill(host, e, previous)
+
+	select {
+	case <-e.ready:
+		r.mu.Lock()
+		addrs, err = e.addrs, e.err
+		r.mu.Unlock()
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: ipAddrs(addrs), Err: err})
+	}
+
+	return addrs, false, err
+}
+
+// fill resolves the host for the entry and marks it ready, previous addresses are used on lookup failure
+func (r *Resolver) fill(host string, e, previous *entry) {
+	addrs, ttl, err := r.resolve(host)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case err == nil:
+		e.addrs = addrs
+		e.expires = r.expiration(ttl)
+	case previous != nil && len(previous.addrs) > 0:
+		// Stale addresses are better than an error
+		e.addrs = previous.addrs
+		e.expires = time.Now().Add(r.negativeTTL)
+	default:
+		e.err = err
+		e.expires = time.Now().Add(r.negativeTTL)
+	}
+	close(e.ready)
+}
+
+// DialContext dials one of the resolved addresses in round-robin order, the next address is tried on failure.
+// Cache hit is stored into profile.Report.
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return r.dialer.DialContext(ctx, network, address)
+	}
+
+	addrs, cached, err := r.LookupIP(ctx, host)
+	if report := profile.ReportFromContext(ctx); report != nil {
+		// The dialer goroutine can outlive the request, the report is updated when the request gets the connection
+		report.SetDNSCacheHit(cached)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	addrs = filterNetwork(network, addrs)
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address found", Name: host}
+	}
+
+	offset := int(atomic.AddUint32(&r.next, 1))
+	for i := range addrs {
+		addr := addrs[(offset+i)%len(addrs)]
+
+		var conn net.Conn
+		conn, err = r.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil || ctx.Err() != nil {
+			return conn, err
+		}
+	}
+
+	return nil, err
+}
+
+// Refresh resolves used hosts again and evicts hosts which have not been used since the previous refresh.
+// Previous addresses are kept on lookup failure.
+func (r *Resolver) Refresh(ctx context.Context) {
+	r.mu.Lock()
+	hosts := make([]string, 0, len(r.entries))
+	for host, e := range r.entries {
+		if !isReady(e) {
+			continue
+		}
+		if !e.used {
+			delete(r.entries, host)
+			continue
+		}
+		e.used = false
+		hosts = append(hosts, host)
+	}
+	r.mu.Unlock()
+
+	for _, host := range hosts {
+		if ctx.Err() != nil {
+			return
+		}
+
+		addrs, ttl, err := r.resolve(host)
+		if err != nil {
+			continue
+		}
+
+		r.mu.Lock()
+		if e, ok := r.entries[host]; ok && isReady(e) {
+			e.addrs = addrs
+			e.err = nil
+			e.expires = r.expiration(ttl)
+		}
+		r.mu.Unlock()
+	}
+}
+
+// RefreshEvery refreshes the cache in background until the context is done (blocking)
+func (r *Resolver) RefreshEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Refresh(ctx)
+			log.EntryFromContextOrDefault(ctx).Debugf("dnscache: %d hosts refreshed", r.Len())
+		}
+	}
+}
+
+// Len number of cached hosts
+func (r *Resolver) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.entries)
+}
+
+// resolve looks the host up on the detached context with the lookup timeout
+func (r *Resolver) resolve(host string) ([]net.IP, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.lookupTimeout)
+	defer cancel()
+
+	ipAddrs, ttl, err := r.lookup(ctx, host)
+	addrs := make([]net.IP, 0, len(ipAddrs))
+	for _, ipAddr := range ipAddrs {
+		addrs = append(addrs, ipAddr.IP)
+	}
+
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return addrs, ttl, err
+}
+
+// expiration of the addresses with the record TTL, the resolver TTL is used when the record TTL is unknown
+func (r *Resolver) expiration(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		ttl = r.ttl
+	}
+	return time.Now().Add(ttl)
+}
+
+func withoutTTL(lookup LookupFn) TTLLookupFn {
+	return func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+		addrs, err := lookup(ctx, host)
+		return addrs, 0, err
+	}
+}
+
+func ipAddrs(addrs []net.IP) []net.IPAddr {
+	ipAddrs := make([]net.IPAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		ipAddrs = append(ipAddrs, net.IPAddr{IP: addr})
+	}
+	return ipAddrs
+}
+
+func isReady(e *entry) bool {
+	select {
+	case <-e.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// filterNetwork IPv4 addresses for "tcp4", IPv6 for "tcp6" and all for "tcp"
+func filterNetwork(network string, addrs []net.IP) []net.IP {
+	if network != "tcp4" && network != "tcp6" {
+		return addrs
+	}
+
+	filtered := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if (addr.To4() != nil) == (network == "tcp4") {
+			filtered = append(filtered, addr)
+		}
+	}
+	return filtered
+}
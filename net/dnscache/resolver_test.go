@@ -0,0 +1,251 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/best-expendables/httpclient/net/profile"
+	"github.com/best-expendables/httpclient/net/transport"
+	"github.com/stretchr/testify/suite"
+)
+
+type ResolverSuite struct {
+	suite.Suite
+
+	lookups int32
+	addrs   []net.IPAddr
+	err     error
+}
+
+func (s *ResolverSuite) SetupTest() {
+	s.lookups = 0
+	s.addrs = []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}
+	s.err = nil
+}
+
+func (s *ResolverSuite) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	atomic.AddInt32(&s.lookups, 1)
+	return s.addrs, s.err
+}
+
+func (s *ResolverSuite) TestLookupIP_Cache() {
+	resolver := New().WithLookup(s.lookup).WithTTL(50 * time.Millisecond)
+
+	addrs, cached, err := resolver.LookupIP(context.Background(), "service.local")
+	s.NoError(err)
+	s.False(cached)
+	s.Equal("127.0.0.1", addrs[0].String())
+
+	addrs, cached, err = resolver.LookupIP(context.Background(), "service.local")
+	s.NoError(err)
+	s.True(cached)
+	s.Equal(int32(1), s.lookups)
+
+	time.Sleep(60 * time.Millisecond)
+	_, cached, _ = resolver.LookupIP(context.Background(), "service.local")
+	s.False(cached)
+	s.Equal(int32(2), s.lookups)
+}
+
+func (s *ResolverSuite) TestLookupIP_Concurrent() {
+	resolver := New().WithLookup(func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		time.Sleep(10 * time.Millisecond)
+		return s.lookup(ctx, host)
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := resolver.LookupIP(context.Background(), "service.local")
+			s.NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	s.Equal(int32(1), atomic.LoadInt32(&s.lookups))
+}
+
+func (s *ResolverSuite) TestLookupIP_CanceledCaller() {
+	release := make(chan struct{})
+	resolver := New().WithLookup(func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		<-release
+		return s.lookup(ctx, host)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, _, err := resolver.LookupIP(ctx, "service.local")
+		first <- err
+	}()
+	for resolver.Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan error)
+	go func() {
+		_, _, err := resolver.LookupIP(context.Background(), "service.local")
+		second <- err
+	}()
+
+	cancel()
+	s.Equal(context.Canceled, <-first)
+
+	close(release)
+	s.NoError(<-second, "waiter is not failed by the canceled caller")
+	s.Equal(int32(1), atomic.LoadInt32(&s.lookups))
+
+	_, cached, err := resolver.LookupIP(context.Background(), "service.local")
+	s.NoError(err)
+	s.True(cached)
+}
+
+func (s *ResolverSuite) TestLookupIP_RecordTTL() {
+	resolver := New().WithTTL(time.Hour).WithTTLLookup(func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+		addrs, err := s.lookup(ctx, host)
+		return addrs, 20 * time.Millisecond, err
+	})
+
+	resolver.LookupIP(context.Background(), "service.local")
+	_, cached, _ := resolver.LookupIP(context.Background(), "service.local")
+	s.True(cached)
+
+	time.Sleep(30 * time.Millisecond)
+	_, cached, _ = resolver.LookupIP(context.Background(), "service.local")
+	s.False(cached, "record TTL has expired")
+	s.Equal(int32(2), s.lookups)
+}
+
+func (s *ResolverSuite) TestLookupIP_NegativeCache() {
+	s.err = errors.New("no such host")
+	resolver := New().WithLookup(s.lookup).WithNegativeTTL(50 * time.Millisecond)
+
+	_, _, err := resolver.LookupIP(context.Background(), "missing.local")
+	s.Error(err)
+	_, cached, err := resolver.LookupIP(context.Background(), "missing.local")
+	s.Error(err)
+	s.True(cached)
+	s.Equal(int32(1), s.lookups)
+
+	time.Sleep(60 * time.Millisecond)
+	s.err = nil
+	_, _, err = resolver.LookupIP(context.Background(), "missing.local")
+	s.NoError(err)
+	s.Equal(int32(2), s.lookups)
+}
+
+func (s *ResolverSuite) TestLookupIP_StaleOnError() {
+	resolver := New().WithLookup(s.lookup).WithTTL(time.Millisecond)
+
+	_, _, err := resolver.LookupIP(context.Background(), "service.local")
+	s.NoError(err)
+
+	time.Sleep(5 * time.Millisecond)
+	s.err = errors.New("server misbehaving")
+	addrs, _, err := resolver.LookupIP(context.Background(), "service.local")
+	s.NoError(err)
+	s.Equal("127.0.0.1", addrs[0].String())
+}
+
+func (s *ResolverSuite) TestLookupIP_Override() {
+	resolver := New().WithLookup(s.lookup).WithOverride("service.local", "10.0.0.1", "invalid")
+
+	addrs, cached, err := resolver.LookupIP(context.Background(), "service.local")
+	s.NoError(err)
+	s.True(cached)
+	s.Len(addrs, 1)
+	s.Equal("10.0.0.1", addrs[0].String())
+	s.Equal(int32(0), s.lookups)
+}
+
+func (s *ResolverSuite) TestRefresh() {
+	resolver := New().WithLookup(s.lookup)
+
+	resolver.LookupIP(context.Background(), "service.local")
+	resolver.LookupIP(context.Background(), "unused.local")
+
+	// Both hosts have been used since the lookup
+	resolver.Refresh(context.Background())
+	s.Equal(int32(4), s.lookups)
+	s.Equal(2, resolver.Len())
+
+	s.addrs = []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}}
+	resolver.LookupIP(context.Background(), "service.local")
+	resolver.Refresh(context.Background())
+	s.Equal(1, resolver.Len())
+
+	addrs, cached, _ := resolver.LookupIP(context.Background(), "service.local")
+	s.True(cached)
+	s.Equal("127.0.0.2", addrs[0].String())
+}
+
+func (s *ResolverSuite) TestRefreshEvery() {
+	resolver := New().WithLookup(s.lookup)
+	resolver.LookupIP(context.Background(), "service.local")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
+	defer cancel()
+	resolver.RefreshEvery(ctx, 10*time.Millisecond)
+
+	s.True(atomic.LoadInt32(&s.lookups) > 1)
+}
+
+func (s *ResolverSuite) TestDialContext() {
+	var remotes []string
+	mu := sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remotes = append(remotes, r.Host)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// Server listens on 127.0.0.1 only, the next address is tried when 127.0.0.2 refuses the connection
+	s.addrs = []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("127.0.0.2")}}
+	resolver := New().WithLookup(s.lookup).WithDialer(&net.Dialer{Timeout: time.Second})
+
+	config := transport.DefaultConfig()
+	config.DialContext = resolver.DialContext
+	config.MaxIdleConnsPerHost = -1
+	client := &http.Client{Transport: transport.New(config)}
+
+	for i := 0; i < 4; i++ {
+		request, _ := http.NewRequest(http.MethodGet, "http://service.local:"+port, nil)
+		request = profile.Observe(request)
+
+		response, err := client.Do(request)
+		s.Require().NoError(err)
+		response.Body.Close()
+
+		report := profile.ReportFromResponse(response)
+		s.Equal(i > 0, report.DNSCacheHit)
+		if i == 0 {
+			s.False(report.DNSLookupDone.IsZero())
+		}
+	}
+
+	s.Len(remotes, 4)
+	s.Equal(int32(1), s.lookups)
+}
+
+func (s *ResolverSuite) TestDialContext_Network() {
+	s.addrs = []net.IPAddr{{IP: net.ParseIP("::1")}}
+	resolver := New().WithLookup(s.lookup)
+
+	_, err := resolver.DialContext(context.Background(), "tcp4", "service.local:80")
+	s.Error(err)
+}
+
+func TestResolverRunner(t *testing.T) {
+	suite.Run(t, new(ResolverSuite))
+}
@@ -0,0 +1,109 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	stdnet "net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+type abandonCtxKey int
+
+const abandonedKey abandonCtxKey = iota
+
+// Classes of the transport errors, see ClassifyError
+const (
+	ErrorClassDNS      = "dns"
+	ErrorClassConnect  = "connect"
+	ErrorClassTLS      = "tls"
+	ErrorClassTimeout  = "timeout"
+	ErrorClassCanceled = "canceled"
+	ErrorClassReset    = "reset"
+	ErrorClassOther    = "other"
+)
+
+// ClassifyError class of the error returned by the transport, empty for nil error.
+// Timeouts of the client or the request context are classified as timeout, dial timeouts as connect.
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+
+	var dnsErr *stdnet.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorClassDNS
+	}
+
+	if isTLSError(err) {
+		return ErrorClassTLS
+	}
+
+	var opErr *stdnet.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return ErrorClassConnect
+	}
+
+	var netErr stdnet.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorClassReset
+	}
+
+	return ErrorClassOther
+}
+
+// ClassifyRequestError class of the transport error of the request with the context.
+// Transport returns the generic "request canceled" error when the request context is done (e.g. client timeout),
+// so the context error is classified instead.
+func ClassifyRequestError(ctx context.Context, err error) string {
+	if err != nil && ctx.Err() != nil {
+		return ClassifyError(ctx.Err())
+	}
+	return ClassifyError(err)
+}
+
+// ContextWithAbandon returns the context of a request which can be abandoned by the caller, e.g. the losing attempt
+// of the hedged request. abandon has to be called before the context is canceled, see IsAbandoned.
+func ContextWithAbandon(ctx context.Context) (context.Context, func()) {
+	abandoned := new(int32)
+	return context.WithValue(ctx, abandonedKey, abandoned), func() {
+		atomic.StoreInt32(abandoned, 1)
+	}
+}
+
+// IsAbandoned the request has been canceled because the caller does not need its result anymore,
+// its error is not a failure of the server
+func IsAbandoned(ctx context.Context) bool {
+	abandoned, ok := ctx.Value(abandonedKey).(*int32)
+	return ok && atomic.LoadInt32(abandoned) == 1 && ctx.Err() != nil
+}
+
+// isTLSError handshake and certificate errors, alerts of the peer are matched by the message ("remote error: tls: ...")
+func isTLSError(err error) bool {
+	var (
+		recordErr    tls.RecordHeaderError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+
+	return errors.As(err, &recordErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) ||
+		strings.Contains(err.Error(), "tls: ")
+}
@@ -0,0 +1,103 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"io"
+	stdnet "net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := map[string]struct {
+		err   error
+		class string
+	}{
+		"nil":      {nil, ""},
+		"canceled": {&url.Error{Op: "Get", Err: context.Canceled}, ErrorClassCanceled},
+		"deadline": {&url.Error{Op: "Get", Err: context.DeadlineExceeded}, ErrorClassTimeout},
+		"dns":      {&url.Error{Op: "Get", Err: &stdnet.OpError{Op: "dial", Err: &stdnet.DNSError{Err: "no such host", Name: "x.invalid"}}}, ErrorClassDNS},
+		"refused":  {&url.Error{Op: "Get", Err: &stdnet.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, ErrorClassConnect},
+		"reset":    {&url.Error{Op: "Get", Err: &stdnet.OpError{Op: "read", Err: syscall.ECONNRESET}}, ErrorClassReset},
+		"eof":      {&url.Error{Op: "Get", Err: io.EOF}, ErrorClassReset},
+		"other":    {errors.New("unexpected"), ErrorClassOther},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, c.class, ClassifyError(c.err))
+		})
+	}
+}
+
+func TestClassifyRequestError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	assert.Equal(t, ErrorClassTimeout, ClassifyRequestError(ctx, errors.New("net/http: request canceled")))
+	assert.Equal(t, ErrorClassOther, ClassifyRequestError(context.Background(), errors.New("net/http: request canceled")))
+	assert.Equal(t, "", ClassifyRequestError(ctx, nil))
+}
+
+func TestClassifyError_Transport(t *testing.T) {
+	t.Run("TLS", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		defer server.Close()
+
+		_, err := http.Get(server.URL)
+		assert.Equal(t, ErrorClassTLS, ClassifyError(err))
+	})
+
+	t.Run("Client timeout", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		}))
+		defer server.Close()
+
+		_, err := (&http.Client{Timeout: 10 * time.Millisecond}).Get(server.URL)
+		assert.Equal(t, ErrorClassTimeout, ClassifyError(err))
+	})
+
+	t.Run("Connection refused", func(t *testing.T) {
+		listener, _ := stdnet.Listen("tcp", "127.0.0.1:0")
+		address := listener.Addr().String()
+		listener.Close()
+
+		_, err := http.Get("http://" + address)
+		assert.Equal(t, ErrorClassConnect, ClassifyError(err))
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		}))
+		defer server.Close()
+
+		_, err := http.Get(server.URL)
+		assert.Equal(t, ErrorClassReset, ClassifyError(err))
+	})
+}
+
+func TestIsAbandoned(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx, abandon := ContextWithAbandon(ctx)
+	assert.False(t, IsAbandoned(ctx))
+
+	abandon()
+	assert.False(t, IsAbandoned(ctx), "not canceled yet")
+
+	cancel()
+	assert.True(t, IsAbandoned(ctx))
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, IsAbandoned(canceled), "canceled by the caller")
+}
@@ -0,0 +1,246 @@
+package profile
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/best-expendables/logger"
+)
+
+// DefaultCollectorWindow number of the latest reports kept per key
+const DefaultCollectorWindow = 1000
+
+// KeyFunc groups reports, e.g. by host or route
+type KeyFunc func(r *http.Request) string
+
+// HostKey groups reports by host
+func HostKey(r *http.Request) string {
+	return r.URL.Host
+}
+
+// Percentiles in milliseconds
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// Stats aggregated statistics for a single key.
+// DNS, Connect and TLS are calculated only for new (non-reused) connections.
+type Stats struct {
+	Key        string      `json:"key"`
+	Count      int         `json:"count"`
+	Reused     int         `json:"reused"`
+	ReuseRatio float64     `json:"reuse_ratio"`
+	DNS        Percentiles `json:"dns"`
+	Connect    Percentiles `json:"connection"`
+	TLS        Percentiles `json:"tls_handshake"`
+	TTFB       Percentiles `json:"ttfb"`
+	Total      Percentiles `json:"total"`
+}
+
+// Collector aggregates reports per key over a sliding window
+type Collector struct {
+	mu     sync.Mutex
+	window int
+	series map[string]*series
+}
+
+// NewCollector creates collector which keeps the latest "window" reports per key
+func NewCollector(window int) *Collector {
+	if window <= 0 {
+		window = DefaultCollectorWindow
+	}
+
+	return &Collector{
+		window: window,
+		series: make(map[string]*series),
+	}
+}
+
+// Add report into the key statistics
+func (c *Collector) Add(key string, report *Report) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.series[key]
+	if !ok {
+		s = newSeries(c.window)
+		c.series[key] = s
+	}
+	s.add(report)
+}
+
+// Snapshot returns statistics sorted by key
+func (c *Collector) Snapshot() []Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make([]Stats, 0, len(c.series))
+	for key, s := range c.series {
+		stats = append(stats, s.stats(key))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Key < stats[j].Key
+	})
+
+	return stats
+}
+
+// Reset removes all collected reports
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.series = make(map[string]*series)
+}
+
+// LogEvery writes the snapshot into the logger with the interval until the context is done.
+// It blocks, so run it in a goroutine.
+func (c *Collector) LogEvery(ctx context.Context, logger log.Entry, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, stats := range c.Snapshot() {
+				logger.WithFields(log.Fields{
+					"source":  "NetworkCollector",
+					"network": stats,
+				}).Info("Network statistics for " + stats.Key)
+			}
+		}
+	}
+}
+
+// series fixed-size ring buffers of timings
+type series struct {
+	count  int
+	reused *ring
+	dns    *ring
+	conn   *ring
+	tls    *ring
+	ttfb   *ring
+	total  *ring
+}
+
+func newSeries(window int) *series {
+	return &series{
+		reused: newRing(window),
+		dns:    newRing(window),
+		conn:   newRing(window),
+		tls:    newRing(window),
+		ttfb:   newRing(window),
+		total:  newRing(window),
+	}
+}
+
+func (s *series) add(report *Report) {
+	s.count++
+
+	if report.Reused {
+		s.reused.add(1)
+	} else {
+		s.reused.add(0)
+		if !report.DNSLookupDone.IsZero() {
+			s.dns.add(report.DNSLookupTimeMs())
+		}
+		if !report.ConnectDone.IsZero() {
+			s.conn.add(report.ConnectionTimeMs())
+		}
+		if !report.TLSHandshakeDone.IsZero() {
+			s.tls.add(report.TLSHandshakeTimeMs())
+		}
+	}
+
+	if !report.FirstResponseByte.IsZero() {
+		s.ttfb.add(report.TimeToFirstByteMs())
+	}
+	s.total.add(report.DurationMs())
+}
+
+func (s *series) stats(key string) Stats {
+	reused := int(s.reused.sum())
+	stats := Stats{
+		Key:     key,
+		Count:   s.count,
+		Reused:  reused,
+		DNS:     s.dns.percentiles(),
+		Connect: s.conn.percentiles(),
+		TLS:     s.tls.percentiles(),
+		TTFB:    s.ttfb.percentiles(),
+		Total:   s.total.percentiles(),
+	}
+	if n := s.reused.len(); n > 0 {
+		stats.ReuseRatio = float64(reused) / float64(n)
+	}
+
+	return stats
+}
+
+type ring struct {
+	values []float64
+	next   int
+	full   bool
+}
+
+func newRing(size int) *ring {
+	return &ring{values: make([]float64, size)}
+}
+
+func (r *ring) add(value float64) {
+	r.values[r.next] = value
+	r.next++
+	if r.next == len(r.values) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+func (r *ring) len() int {
+	if r.full {
+		return len(r.values)
+	}
+	return r.next
+}
+
+func (r *ring) sum() float64 {
+	var sum float64
+	for _, v := range r.values[:r.len()] {
+		sum += v
+	}
+	return sum
+}
+
+func (r *ring) percentiles() Percentiles {
+	n := r.len()
+	if n == 0 {
+		return Percentiles{}
+	}
+
+	sorted := make([]float64, n)
+	copy(sorted, r.values[:n])
+	sort.Float64s(sorted)
+
+	return Percentiles{
+		P50: Percentile(sorted, 0.5),
+		P90: Percentile(sorted, 0.9),
+		P99: Percentile(sorted, 0.99),
+	}
+}
+
+// Percentile nearest-rank percentile p (e.g. 0.95) of the values, values have to be sorted
+func Percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
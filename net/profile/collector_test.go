@@ -0,0 +1,85 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/best-expendables/logger"
+)
+
+func TestCollector_Snapshot(t *testing.T) {
+	collector := NewCollector(10)
+
+	for i := 1; i <= 20; i++ {
+		collector.Add("a.io", &Report{
+			Start:             timestamp,
+			FirstResponseByte: timestamp.Add(time.Duration(i) * time.Millisecond),
+			Done:              timestamp.Add(time.Duration(i) * time.Millisecond),
+			Reused:            i%2 == 0,
+		})
+	}
+	collector.Add("b.io", &Report{
+		Start:          timestamp,
+		Done:           timestamp.Add(time.Second),
+		DNSLookupStart: timestamp,
+		DNSLookupDone:  timestamp.Add(time.Millisecond),
+	})
+
+	stats := collector.Snapshot()
+	if len(stats) != 2 || stats[0].Key != "a.io" || stats[1].Key != "b.io" {
+		t.Fatalf("Unexpected snapshot %+v", stats)
+	}
+
+	a := stats[0]
+	if a.Count != 20 {
+		t.Errorf("Count not equals %d", a.Count)
+	}
+	if a.ReuseRatio != 0.5 {
+		t.Errorf("Reuse ratio not equals %f", a.ReuseRatio)
+	}
+	// Only the latest 10 reports (11..20ms)
+	if a.Total.P50 != 15 || a.Total.P90 != 19 || a.Total.P99 != 20 {
+		t.Errorf("Unexpected total percentiles %+v", a.Total)
+	}
+	if a.TTFB != a.Total {
+		t.Errorf("Unexpected TTFB percentiles %+v", a.TTFB)
+	}
+
+	b := stats[1]
+	if b.DNS.P50 != 1 || b.Total.P99 != 1000 || b.ReuseRatio != 0 {
+		t.Errorf("Unexpected stats %+v", b)
+	}
+
+	collector.Reset()
+	if len(collector.Snapshot()) != 0 {
+		t.Error("Collector should be empty")
+	}
+}
+
+func TestCollector_LogEvery(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger := log.NewLoggerFactory(log.InfoLevel, log.SetOut(buffer)).Logger(context.TODO())
+
+	collector := NewCollector(0)
+	collector.Add("a.io", &Report{Start: timestamp, Done: timestamp.Add(time.Second)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	collector.LogEvery(ctx, logger, 10*time.Millisecond)
+
+	if !strings.Contains(buffer.String(), "Network statistics for a.io") {
+		t.Errorf("Statistics have not been logged: %s", buffer.String())
+	}
+}
+
+func TestHostKey(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "http://a.io:8080/v1/users", nil)
+
+	if HostKey(r) != "a.io:8080" {
+		t.Error("Key not equals")
+	}
+}
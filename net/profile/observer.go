@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"net/http"
 	"net/http/httptrace"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,7 +15,7 @@ const reportCtxKey ctxKey = iota
 
 // Observe request
 func Observe(r *http.Request) *http.Request {
-	report := new(Report)
+	report := &Report{Start: time.Now()}
 
 	ctx := httptrace.WithClientTrace(
 		r.Context(), observer(report),
@@ -27,8 +28,12 @@ func Observe(r *http.Request) *http.Request {
 
 // ReportFromResponse return report from response
 func ReportFromResponse(response *http.Response) *Report {
-	ctx := response.Request.Context()
+	return ReportFromContext(response.Request.Context())
+}
 
+// ReportFromContext return report from request context.
+// The report may be incomplete until the request is done.
+func ReportFromContext(ctx context.Context) *Report {
 	if report, ok := ctx.Value(reportCtxKey).(*Report); ok {
 		return report
 	}
@@ -38,8 +43,22 @@ func ReportFromResponse(response *http.Response) *Report {
 
 func observer(report *Report) *httptrace.ClientTrace {
 	return &httptrace.ClientTrace{
+		GetConn: func(_ string) {
+			report.GetConn = time.Now()
+		},
 		GotConn: func(conn httptrace.GotConnInfo) {
+			report.GotConn = time.Now()
 			report.Reused = conn.Reused
+			report.WasIdle = conn.WasIdle
+			report.IdleTime = conn.IdleTime
+			report.DNSCacheHit = !conn.Reused && atomic.LoadInt32(&report.dnsCacheHit) == 1
+			if conn.Conn != nil {
+				report.RemoteAddr = conn.Conn.RemoteAddr().String()
+				report.LocalAddr = conn.Conn.LocalAddr().String()
+			}
+			if tlsConn, ok := conn.Conn.(*tls.Conn); ok {
+				report.setTLSState(tlsConn.ConnectionState())
+			}
 		},
 		ConnectStart: func(_, _ string) {
 			report.ConnectStart = time.Now()
@@ -58,10 +77,22 @@ func observer(report *Report) *httptrace.ClientTrace {
 		TLSHandshakeStart: func() {
 			report.TLSHandshakeStart = time.Now()
 		},
-		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
 			if err == nil {
 				report.TLSHandshakeDone = time.Now()
+				report.setTLSState(state)
 			}
 		},
+		WroteHeaders: func() {
+			report.WroteHeaders = time.Now()
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				report.WroteRequest = time.Now()
+			}
+		},
+		GotFirstResponseByte: func() {
+			report.FirstResponseByte = time.Now()
+		},
 	}
 }
@@ -1,6 +1,8 @@
 package profile
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -39,6 +41,30 @@ func TestObserve(t *testing.T) {
 	}
 }
 
+func TestObserve_DNSCacheHit(t *testing.T) {
+	dialer := &net.Dialer{}
+	c := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if report := ReportFromContext(ctx); report != nil {
+				report.SetDNSCacheHit(true)
+			}
+			return dialer.DialContext(ctx, network, address)
+		},
+	}}
+
+	response := sendRequest(c)
+	response.Body.Close()
+	if !ReportFromResponse(response).DNSCacheHit {
+		t.Error("DNSCacheHit should be set for the dialed connection")
+	}
+
+	response = sendRequest(c)
+	response.Body.Close()
+	if ReportFromResponse(response).DNSCacheHit {
+		t.Error("DNSCacheHit should not be set for the reused connection")
+	}
+}
+
 func BenchmarkObserve10000(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		r, _ := http.NewRequest(http.MethodGet, "", nil)
@@ -51,3 +77,30 @@ func sendRequest(c *http.Client) *http.Response {
 	res, _ := c.Do(Observe(req))
 	return res
 }
+
+func TestObserve_TLS(t *testing.T) {
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer tlsSrv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, tlsSrv.URL, nil)
+	res, err := tlsSrv.Client().Do(Observe(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := ReportFromResponse(res)
+
+	if report.TLSVersion == 0 || report.TLSHandshakeDone.IsZero() {
+		t.Error("TLS details are missing")
+	}
+
+	if report.RemoteAddr != tlsSrv.Listener.Addr().String() {
+		t.Errorf("Remote address not equals: %s", report.RemoteAddr)
+	}
+
+	if report.FirstResponseByte.IsZero() || report.WroteRequest.IsZero() || report.GotConn.IsZero() {
+		t.Error("Request timings are missing")
+	}
+}
@@ -1,12 +1,24 @@
 package profile
 
 import (
+	"crypto/tls"
 	"math"
+	"sync/atomic"
 	"time"
 )
 
 // Report for single HTTP Request
 type Report struct {
+	// Start time when the request has been observed
+	Start time.Time
+	// Done time when the response headers have been received, see Finish
+	Done time.Time
+
+	// GetConn time when client start waiting a connection (from the pool or a new one)
+	GetConn time.Time
+	// GotConn time when client got a connection
+	GotConn time.Time
+
 	// ConnectStart time when client start connection
 	ConnectStart time.Time
 	// ConnectDone time when connection has been established
@@ -16,14 +28,87 @@ type Report struct {
 	DNSLookupStart time.Time
 	// DNSLookupDone end of DNS lookup
 	DNSLookupDone time.Time
+	// DNSCacheHit address has been taken from in-process DNS cache, see net/dnscache.
+	// Set when the new connection is obtained, see SetDNSCacheHit
+	DNSCacheHit bool
+	// dnsCacheHit is set by the dialer goroutine, 1 for the cache hit
+	dnsCacheHit int32
 
 	// TLSHandshakeStart begin of TLS handshake
 	TLSHandshakeStart time.Time
 	// TLSHandshakeDone end of TLS handshake
 	TLSHandshakeDone time.Time
 
+	// WroteHeaders time when request headers have been written
+	WroteHeaders time.Time
+	// WroteRequest time when the whole request (with body) has been written
+	WroteRequest time.Time
+	// FirstResponseByte time when the first byte of the response has been received
+	FirstResponseByte time.Time
+
 	// Reused connection from connection pool (keep-alive)
 	Reused bool
+	// WasIdle connection has been idle in the pool
+	WasIdle bool
+	// IdleTime how long the connection has been idle in the pool
+	IdleTime time.Duration
+
+	// RemoteAddr address of the server
+	RemoteAddr string
+	// LocalAddr local address of the connection
+	LocalAddr string
+
+	// TLSVersion negotiated TLS version, e.g. tls.VersionTLS12. Zero for plain HTTP
+	TLSVersion uint16
+	// TLSCipherSuite negotiated cipher suite
+	TLSCipherSuite uint16
+	// NegotiatedProtocol application protocol negotiated by ALPN, e.g. "h2" or "http/1.1"
+	NegotiatedProtocol string
+	// TLSResumed session has been resumed from a previous connection
+	TLSResumed bool
+}
+
+// SetDNSCacheHit records whether the address of the dialed connection has been taken from the cache.
+// It is safe to call from the dialer goroutine, DNSCacheHit is updated when the request gets the connection.
+func (r *Report) SetDNSCacheHit(hit bool) {
+	var value int32
+	if hit {
+		value = 1
+	}
+	atomic.StoreInt32(&r.dnsCacheHit, value)
+}
+
+// Finish marks the request as done
+func (r *Report) Finish() {
+	r.Done = time.Now()
+}
+
+// Duration total time of the request. Elapsed time for a request which is not done yet
+func (r *Report) Duration() time.Duration {
+	if r.Done.IsZero() {
+		return time.Since(r.Start)
+	}
+	return r.Done.Sub(r.Start)
+}
+
+// DurationMs total time of the request in milliseconds
+func (r *Report) DurationMs() float64 {
+	return toMilliseconds(r.Duration())
+}
+
+// ConnectionWaitTime time for getting a connection (pool wait, DNS, connect and TLS handshake)
+func (r *Report) ConnectionWaitTime() time.Duration {
+	return r.GotConn.Sub(r.GetConn)
+}
+
+// ConnectionWaitTimeMs time for getting a connection in milliseconds
+func (r *Report) ConnectionWaitTimeMs() float64 {
+	return toMilliseconds(r.ConnectionWaitTime())
+}
+
+// IdleTimeMs how long the reused connection has been idle in milliseconds
+func (r *Report) IdleTimeMs() float64 {
+	return toMilliseconds(r.IdleTime)
 }
 
 // ConnectionTime time for establishing a connection
@@ -56,6 +141,68 @@ func (r *Report) TLSHandshakeTimeMs() float64 {
 	return toMilliseconds(r.TLSHandshakeTime())
 }
 
+// WriteTime time for writing the request (headers and body) into the connection
+func (r *Report) WriteTime() time.Duration {
+	return r.WroteRequest.Sub(r.GotConn)
+}
+
+// WriteTimeMs time for writing the request in milliseconds
+func (r *Report) WriteTimeMs() float64 {
+	return toMilliseconds(r.WriteTime())
+}
+
+// TimeToFirstByte time from the start of the request to the first byte of the response (TTFB)
+func (r *Report) TimeToFirstByte() time.Duration {
+	return r.FirstResponseByte.Sub(r.Start)
+}
+
+// TimeToFirstByteMs TTFB in milliseconds
+func (r *Report) TimeToFirstByteMs() float64 {
+	return toMilliseconds(r.TimeToFirstByte())
+}
+
+// ServerTime time between the written request and the first byte of the response
+func (r *Report) ServerTime() time.Duration {
+	return r.FirstResponseByte.Sub(r.WroteRequest)
+}
+
+// ServerTimeMs time between the written request and the first byte of the response in milliseconds
+func (r *Report) ServerTimeMs() float64 {
+	return toMilliseconds(r.ServerTime())
+}
+
+// TLSVersionName human readable TLS version, empty for plain HTTP
+func (r *Report) TLSVersionName() string {
+	switch r.TLSVersion {
+	case 0:
+		return ""
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return "unknown"
+}
+
+// TLSCipherSuiteName human readable cipher suite, empty for plain HTTP
+func (r *Report) TLSCipherSuiteName() string {
+	if r.TLSVersion == 0 {
+		return ""
+	}
+	return tls.CipherSuiteName(r.TLSCipherSuite)
+}
+
+func (r *Report) setTLSState(state tls.ConnectionState) {
+	r.TLSVersion = state.Version
+	r.TLSCipherSuite = state.CipherSuite
+	r.NegotiatedProtocol = state.NegotiatedProtocol
+	r.TLSResumed = state.DidResume
+}
+
 // toMilliseconds converts duration to milliseconds, precision 0.02.
 func toMilliseconds(duration time.Duration) float64 {
 	if duration < time.Microsecond*10 {
@@ -1,6 +1,7 @@
 package profile
 
 import (
+	"crypto/tls"
 	"testing"
 	"time"
 )
@@ -53,3 +54,53 @@ func TestReport_TLSHandshakeDuration(t *testing.T) {
 		t.Error("Duration in milliseconds not equals")
 	}
 }
+
+func TestReport_TimeToFirstByte(t *testing.T) {
+	report := Report{
+		Start:             timestamp,
+		GetConn:           timestamp,
+		GotConn:           timestamp.Add(time.Second),
+		WroteRequest:      timestamp.Add(2 * time.Second),
+		FirstResponseByte: timestamp.Add(5 * time.Second),
+		Done:              timestamp.Add(6 * time.Second),
+	}
+
+	if report.ConnectionWaitTimeMs() != 1000 {
+		t.Error("Connection wait time not equals")
+	}
+
+	if report.WriteTimeMs() != 1000 {
+		t.Error("Write time not equals")
+	}
+
+	if report.ServerTimeMs() != 3000 {
+		t.Error("Server time not equals")
+	}
+
+	if report.TimeToFirstByteMs() != 5000 {
+		t.Error("TTFB not equals")
+	}
+
+	if report.DurationMs() != 6000 {
+		t.Error("Duration not equals")
+	}
+}
+
+func TestReport_TLSNames(t *testing.T) {
+	report := Report{}
+
+	if report.TLSVersionName() != "" || report.TLSCipherSuiteName() != "" {
+		t.Error("TLS names should be empty for plain HTTP")
+	}
+
+	report.TLSVersion = tls.VersionTLS12
+	report.TLSCipherSuite = tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+
+	if report.TLSVersionName() != "TLS 1.2" {
+		t.Errorf("Unexpected TLS version %s", report.TLSVersionName())
+	}
+
+	if report.TLSCipherSuiteName() != "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256" {
+		t.Errorf("Unexpected cipher suite %s", report.TLSCipherSuiteName())
+	}
+}
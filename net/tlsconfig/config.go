@@ -0,0 +1,140 @@
+package tlsconfig
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// ErrPinMismatch none of the server certificates matches the pinned SPKI hashes
+var ErrPinMismatch = errors.New("tlsconfig: server certificate does not match pinned public keys")
+
+// Option configures tls.Config
+type Option func(config *tls.Config) error
+
+// New creates client TLS configuration, TLS 1.2 is the minimum version by default
+func New(opts ...Option) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	for _, opt := range opts {
+		if err := opt(config); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// WithClientCertificate client certificate for mutual TLS, the files are reloaded when they change on disk
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(config *tls.Config) error {
+		reloader, err := NewCertificateReloader(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		config.GetClientCertificate = reloader.GetClientCertificate
+		return nil
+	}
+}
+
+// WithClientKeyPair static client certificate for mutual TLS
+func WithClientKeyPair(certificate tls.Certificate) Option {
+	return func(config *tls.Config) error {
+		config.Certificates = []tls.Certificate{certificate}
+		return nil
+	}
+}
+
+// WithRootCAs trusted root certificates instead of the system pool
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(config *tls.Config) error {
+		config.RootCAs = pool
+		return nil
+	}
+}
+
+// WithRootCAFile trusted root certificates from PEM file instead of the system pool
+func WithRootCAFile(path string) Option {
+	return func(config *tls.Config) error {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return fmt.Errorf("tlsconfig: no certificates found in %s", path)
+		}
+		config.RootCAs = pool
+
+		return nil
+	}
+}
+
+// WithMinVersion minimum TLS version, e.g. tls.VersionTLS13
+func WithMinVersion(version uint16) Option {
+	return func(config *tls.Config) error {
+		config.MinVersion = version
+		return nil
+	}
+}
+
+// WithServerName overrides SNI and the name used for the certificate verification
+func WithServerName(name string) Option {
+	return func(config *tls.Config) error {
+		config.ServerName = name
+		return nil
+	}
+}
+
+// WithPinnedSPKI accepts only verified server chains with at least one certificate
+// matching base64 encoded SHA-256 hash of SubjectPublicKeyInfo (HPKP format).
+// With InsecureSkipVerify only the leaf certificate is checked.
+//
+// E.g.:
+//
+//	openssl x509 -in cert.pem -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func WithPinnedSPKI(hashes ...string) Option {
+	return func(config *tls.Config) error {
+		pins := make(map[string]bool, len(hashes))
+		for _, hash := range hashes {
+			if decoded, err := base64.StdEncoding.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+				return fmt.Errorf("tlsconfig: invalid SPKI hash %q", hash)
+			}
+			pins[hash] = true
+		}
+
+		// VerifyConnection is called for resumed sessions too, unlike VerifyPeerCertificate
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			// Only verified chains are trusted, the peer can append any certificate to the presented ones
+			for _, chain := range state.VerifiedChains {
+				for _, cert := range chain {
+					if pins[SPKIHash(cert)] {
+						return nil
+					}
+				}
+			}
+			// Verified chains are missing only when the config of the handshake (e.g. a clone made by the transport)
+			// skips the verification, only the leaf is bound to the handshake then
+			if len(state.VerifiedChains) == 0 && len(state.PeerCertificates) > 0 &&
+				pins[SPKIHash(state.PeerCertificates[0])] {
+				return nil
+			}
+			return ErrPinMismatch
+		}
+
+		return nil
+	}
+}
+
+// SPKIHash base64 encoded SHA-256 hash of the certificate SubjectPublicKeyInfo
+func SPKIHash(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,246 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigSuite struct {
+	suite.Suite
+
+	dir    string
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	server *httptest.Server
+	// serverCert genuine certificate of the server
+	serverCert *x509.Certificate
+	// common name of the client certificate seen by the server
+	clientCN string
+}
+
+func (s *ConfigSuite) SetupTest() {
+	var err error
+	s.dir, err = ioutil.TempDir("", "tlsconfig")
+	s.Require().NoError(err)
+
+	s.ca, s.caKey = s.certificate("ca", nil, nil)
+	s.writePEM("ca.pem", "CERTIFICATE", s.ca.Raw)
+
+	serverCert, serverKey := s.certificate("service.mesh", s.ca, s.caKey)
+	s.serverCert = serverCert
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(s.ca)
+
+	s.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.clientCN = r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	s.server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	s.server.StartTLS()
+
+	s.writeClientCertificate("client-1")
+}
+
+func (s *ConfigSuite) TearDownTest() {
+	s.server.Close()
+	os.RemoveAll(s.dir)
+}
+
+func (s *ConfigSuite) TestMutualTLSWithReload() {
+	config, err := New(
+		WithRootCAFile(s.path("ca.pem")),
+		WithServerName("service.mesh"),
+		WithClientCertificate(s.path("client.pem"), s.path("client-key.pem")),
+	)
+	s.Require().NoError(err)
+
+	s.NoError(s.get(config))
+	s.Equal("client-1", s.clientCN)
+
+	s.writeClientCertificate("client-2")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(s.path("client.pem"), future, future)
+
+	s.NoError(s.get(config))
+	s.Equal("client-2", s.clientCN)
+
+	// Broken files keep the previous certificate
+	ioutil.WriteFile(s.path("client.pem"), []byte("broken"), 0600)
+	os.Chtimes(s.path("client.pem"), future.Add(time.Minute), future.Add(time.Minute))
+
+	s.NoError(s.get(config))
+	s.Equal("client-2", s.clientCN)
+}
+
+func (s *ConfigSuite) TestServerNameMismatch() {
+	config, err := New(
+		WithRootCAFile(s.path("ca.pem")),
+		WithClientCertificate(s.path("client.pem"), s.path("client-key.pem")),
+	)
+	s.Require().NoError(err)
+
+	// Server certificate is issued for service.mesh, not for 127.0.0.1
+	s.Error(s.get(config))
+}
+
+func (s *ConfigSuite) TestPinnedSPKI() {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.ca)
+
+	config, err := New(
+		WithRootCAs(pool),
+		WithServerName("service.mesh"),
+		WithMinVersion(tls.VersionTLS13),
+		WithClientCertificate(s.path("client.pem"), s.path("client-key.pem")),
+		WithPinnedSPKI(SPKIHash(s.ca)),
+	)
+	s.Require().NoError(err)
+	s.Equal(uint16(tls.VersionTLS13), config.MinVersion)
+	s.NoError(s.get(config))
+
+	other, _ := s.certificate("other", nil, nil)
+	config, err = New(
+		WithRootCAs(pool),
+		WithServerName("service.mesh"),
+		WithClientCertificate(s.path("client.pem"), s.path("client-key.pem")),
+		WithPinnedSPKI(SPKIHash(other)),
+	)
+	s.Require().NoError(err)
+	s.Error(s.get(config))
+
+	_, err = New(WithPinnedSPKI("not-a-hash"))
+	s.Error(err)
+}
+
+func (s *ConfigSuite) TestPinnedSPKIAppendedCertificate() {
+	// Certificate mis-issued by the trusted CA, the pinned genuine certificate is appended to the chain
+	misissued, misissuedKey := s.certificate("service.mesh", s.ca, s.caKey)
+	attacker := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	attacker.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{misissued.Raw, s.serverCert.Raw}, PrivateKey: misissuedKey}},
+	}
+	attacker.StartTLS()
+	defer attacker.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(s.ca)
+
+	config, err := New(
+		WithRootCAs(pool),
+		WithServerName("service.mesh"),
+		WithPinnedSPKI(SPKIHash(s.serverCert)),
+	)
+	s.Require().NoError(err)
+	s.True(errors.Is(s.getURL(config, attacker.URL), ErrPinMismatch))
+
+	insecure, err := New(WithPinnedSPKI(SPKIHash(s.serverCert)))
+	s.Require().NoError(err)
+	insecure.InsecureSkipVerify = true
+	s.True(errors.Is(s.getURL(insecure, attacker.URL), ErrPinMismatch), "only the leaf is checked without verification")
+
+	insecure.Certificates = []tls.Certificate{s.clientCertificate()}
+	s.NoError(s.getURL(insecure, s.server.URL))
+
+	// Transport uses a clone of the config, InsecureSkipVerify is set on the clone only
+	pinned, err := New(WithPinnedSPKI(SPKIHash(s.serverCert)))
+	s.Require().NoError(err)
+	cloned := pinned.Clone()
+	cloned.InsecureSkipVerify = true
+	cloned.Certificates = []tls.Certificate{s.clientCertificate()}
+	s.NoError(s.getURL(cloned, s.server.URL))
+	s.True(errors.Is(s.getURL(cloned, attacker.URL), ErrPinMismatch))
+}
+
+func (s *ConfigSuite) TestInvalidFiles() {
+	_, err := New(WithRootCAFile(s.path("missing.pem")))
+	s.Error(err)
+
+	_, err = New(WithClientCertificate(s.path("ca.pem"), s.path("missing.pem")))
+	s.Error(err)
+}
+
+func (s *ConfigSuite) get(config *tls.Config) error {
+	return s.getURL(config, s.server.URL)
+}
+
+func (s *ConfigSuite) getURL(config *tls.Config, url string) error {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	response, err := client.Get(url)
+	if err == nil {
+		response.Body.Close()
+	}
+	return err
+}
+
+func (s *ConfigSuite) writeClientCertificate(commonName string) {
+	cert, key := s.certificate(commonName, s.ca, s.caKey)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	s.writePEM("client.pem", "CERTIFICATE", cert.Raw)
+	s.writePEM("client-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func (s *ConfigSuite) clientCertificate() tls.Certificate {
+	certificate, err := tls.LoadX509KeyPair(s.path("client.pem"), s.path("client-key.pem"))
+	s.Require().NoError(err)
+	return certificate
+}
+
+func (s *ConfigSuite) certificate(commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	s.Require().NoError(err)
+	cert, _ := x509.ParseCertificate(der)
+
+	return cert, key
+}
+
+func (s *ConfigSuite) writePEM(name, blockType string, der []byte) {
+	content := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	s.Require().NoError(ioutil.WriteFile(s.path(name), content, 0600))
+}
+
+func (s *ConfigSuite) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func TestConfigRunner(t *testing.T) {
+	suite.Run(t, new(ConfigSuite))
+}
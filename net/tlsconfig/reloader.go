@@ -0,0 +1,76 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateReloader loads a key pair and reloads it when the files change on disk (e.g. rotated by cert-manager)
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// NewCertificateReloader loads the key pair, an error is returned if the files are invalid
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	reloader := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if _, err := reloader.Certificate(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// Certificate returns the current key pair, files are reloaded if they have been modified.
+// The previous key pair is kept if the new files can not be loaded (e.g. partially written).
+func (r *CertificateReloader) Certificate() (*tls.Certificate, error) {
+	certInfo, certErr := os.Stat(r.certFile)
+	keyInfo, keyErr := os.Stat(r.keyFile)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if certErr != nil || keyErr != nil {
+		if r.certificate != nil {
+			return r.certificate, nil
+		}
+		if certErr != nil {
+			return nil, certErr
+		}
+		return nil, keyErr
+	}
+
+	if r.certificate != nil && certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return r.certificate, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.certificate != nil {
+			return r.certificate, nil
+		}
+		return nil, err
+	}
+
+	r.certificate = &certificate
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+
+	return r.certificate, nil
+}
+
+// GetClientCertificate callback for tls.Config
+func (r *CertificateReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate()
+}
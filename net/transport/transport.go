@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Production defaults, unlike http.DefaultTransport more idle connections are kept per host
+const (
+	DefaultMaxIdleConns          = 100
+	DefaultMaxIdleConnsPerHost   = 32
+	DefaultIdleConnTimeout       = 90 * time.Second
+	DefaultDialTimeout           = 5 * time.Second
+	DefaultKeepAlive             = 30 * time.Second
+	DefaultTLSHandshakeTimeout   = 10 * time.Second
+	DefaultExpectContinueTimeout = time.Second
+)
+
+type (
+	// DialContextFn dials the connection, e.g. custom resolver or socket options
+	DialContextFn func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// ProxyFn returns proxy for the request, nil URL means no proxy
+	ProxyFn func(r *http.Request) (*url.URL, error)
+
+	// Config of the HTTP transport, zero values mean no limit (or no timeout)
+	Config struct {
+		MaxIdleConns        int
+		MaxIdleConnsPerHost int
+		MaxConnsPerHost     int
+		IdleConnTimeout     time.Duration
+
+		DialTimeout time.Duration
+		KeepAlive   time.Duration
+		// DialContext replaces the default dialer, DialTimeout and KeepAlive are ignored
+		DialContext DialContextFn
+
+		TLSHandshakeTimeout   time.Duration
+		ResponseHeaderTimeout time.Duration
+		ExpectContinueTimeout time.Duration
+
+		// DisableHTTP2 uses HTTP/1.1 only
+		DisableHTTP2 bool
+		// ForceHTTP2 attempts HTTP/2 even with custom dialer or TLS configuration
+		ForceHTTP2 bool
+
+		// Proxy nil means no proxy, see http.ProxyFromEnvironment
+		Proxy     ProxyFn
+		TLSConfig *tls.Config
+	}
+)
+
+// DefaultConfig production defaults, proxy is taken from the environment
+func DefaultConfig() Config {
+	return Config{
+		MaxIdleConns:          DefaultMaxIdleConns,
+		MaxIdleConnsPerHost:   DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:       DefaultIdleConnTimeout,
+		DialTimeout:           DefaultDialTimeout,
+		KeepAlive:             DefaultKeepAlive,
+		TLSHandshakeTimeout:   DefaultTLSHandshakeTimeout,
+		ExpectContinueTimeout: DefaultExpectContinueTimeout,
+		ForceHTTP2:            true,
+		Proxy:                 http.ProxyFromEnvironment,
+	}
+}
+
+// New creates transport with own connection pool
+func New(config Config) *http.Transport {
+	dialContext := config.DialContext
+	if dialContext == nil {
+		dialContext = (&net.Dialer{
+			Timeout:   config.DialTimeout,
+			KeepAlive: config.KeepAlive,
+		}).DialContext
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialContext,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		ExpectContinueTimeout: config.ExpectContinueTimeout,
+		ForceAttemptHTTP2:     config.ForceHTTP2 && !config.DisableHTTP2,
+	}
+	if config.TLSConfig != nil {
+		// HTTP/2 setup modifies the configuration, it must not be shared between transports
+		transport.TLSClientConfig = config.TLSConfig.Clone()
+	}
+	if config.Proxy != nil {
+		transport.Proxy = config.Proxy
+	}
+	if config.DisableHTTP2 {
+		// Non-nil empty map disables HTTP/2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		if transport.TLSClientConfig != nil {
+			transport.TLSClientConfig.NextProtos = withoutHTTP2(transport.TLSClientConfig.NextProtos)
+		}
+	}
+
+	return transport
+}
+
+// NewDefault creates transport with DefaultConfig
+func NewDefault() *http.Transport {
+	return New(DefaultConfig())
+}
+
+func withoutHTTP2(protos []string) []string {
+	filtered := make([]string, 0, len(protos))
+	for _, proto := range protos {
+		if proto != "h2" {
+			filtered = append(filtered, proto)
+		}
+	}
+	return filtered
+}
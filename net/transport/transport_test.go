@@ -0,0 +1,109 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDefault(t *testing.T) {
+	transport := NewDefault()
+
+	asserts := assert.New(t)
+	asserts.True(http.DefaultTransport != http.RoundTripper(transport))
+	asserts.Equal(DefaultMaxIdleConns, transport.MaxIdleConns)
+	asserts.Equal(DefaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	asserts.Equal(DefaultIdleConnTimeout, transport.IdleConnTimeout)
+	asserts.Equal(DefaultTLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+	asserts.Equal(DefaultExpectContinueTimeout, transport.ExpectContinueTimeout)
+	asserts.True(transport.ForceAttemptHTTP2)
+	asserts.NotNil(transport.Proxy)
+	asserts.NotNil(transport.DialContext)
+}
+
+func TestNew(t *testing.T) {
+	proxyURL, _ := url.Parse("http://proxy:3128")
+	tlsConfig := &tls.Config{ServerName: "example.com"}
+
+	config := DefaultConfig()
+	config.MaxConnsPerHost = 10
+	config.ResponseHeaderTimeout = time.Second
+	config.DisableHTTP2 = true
+	config.TLSConfig = tlsConfig
+	config.Proxy = http.ProxyURL(proxyURL)
+
+	transport := New(config)
+
+	asserts := assert.New(t)
+	asserts.Equal(10, transport.MaxConnsPerHost)
+	asserts.Equal(time.Second, transport.ResponseHeaderTimeout)
+	asserts.False(transport.ForceAttemptHTTP2)
+	asserts.NotNil(transport.TLSNextProto)
+	asserts.Empty(transport.TLSNextProto)
+	asserts.Equal("example.com", transport.TLSClientConfig.ServerName)
+	asserts.True(tlsConfig != transport.TLSClientConfig)
+
+	proxy, err := transport.Proxy(httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	asserts.NoError(err)
+	asserts.Equal(proxyURL, proxy)
+
+	transport = New(Config{})
+	asserts.Nil(transport.Proxy)
+	asserts.Nil(transport.TLSNextProto)
+}
+
+func TestNew_HTTP2(t *testing.T) {
+	var offered []string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			offered = hello.SupportedProtos
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.TLSConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+	response, err := (&http.Client{Transport: New(config)}).Get(server.URL)
+	if assert.NoError(t, err) {
+		response.Body.Close()
+	}
+	assert.Contains(t, offered, "h2")
+
+	config.DisableHTTP2 = true
+	response, err = (&http.Client{Transport: New(config)}).Get(server.URL)
+	if assert.NoError(t, err) {
+		response.Body.Close()
+		assert.Equal(t, 1, response.ProtoMajor)
+	}
+	assert.NotContains(t, offered, "h2")
+}
+
+func TestNew_DialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dialed := ""
+	config := DefaultConfig()
+	config.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = address
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+
+	response, err := (&http.Client{Transport: New(config)}).Get("http://service.local:8080")
+	if assert.NoError(t, err) {
+		response.Body.Close()
+	}
+	assert.Equal(t, "service.local:8080", dialed)
+}